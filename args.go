@@ -0,0 +1,319 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	
+	"github.com/abc1236762/pixiv_tool/pixiv"
+)
+
+// EnvPrefix is the prefix of environment variables of config.
+const EnvPrefix = "PIXIV_"
+
+// An ArgType is the type of the value of an argument.
+type ArgType uint8
+
+// Values of DurationArg are like "1m30s" and saved in strings, values of
+// ListArg are appended when the argument is given several times and saved
+// in slices of strings.
+const (
+	BoolArg ArgType = iota
+	IntArg
+	StringArg
+	DurationArg
+	ListArg
+)
+
+// String get the name of ArgType.
+func (at ArgType) String() string {
+	switch at {
+	case BoolArg:
+		return "bool"
+	case IntArg:
+		return "int"
+	case StringArg:
+		return "string"
+	case DurationArg:
+		return "duration"
+	case ListArg:
+		return "list"
+	default:
+		return "unknown"
+	}
+}
+
+// Kind get the kind of fields that save values of ArgType.
+func (at ArgType) Kind() reflect.Kind {
+	switch at {
+	case BoolArg:
+		return reflect.Bool
+	case IntArg:
+		return reflect.Int
+	case ListArg:
+		return reflect.Slice
+	default:
+		return reflect.String
+	}
+}
+
+// An argParser parse arguments by ArgData and set values to fields of
+// Value, Name is the command like "download" used in error messages.
+type argParser struct {
+	Name    string
+	ArgData map[string]ArgData
+	Value   reflect.Value
+	
+	givenArgs map[string]bool
+	errMsgs   []string
+}
+
+// getArgName get the name of field of the argument like "--path" or "-p",
+// it is empty if the argument is not found.
+func (ap *argParser) getArgName(arg string) string {
+	for name, data := range ap.ArgData {
+		if arg == "--"+data.LongCmd ||
+				(data.ShortCmd != "" && arg == "-"+data.ShortCmd) {
+			return name
+		}
+	}
+	return ""
+}
+
+// getPositionalName get the name of field of the argument that take
+// positional values, it is empty if the command does not have it.
+func (ap *argParser) getPositionalName() string {
+	for name, data := range ap.ArgData {
+		if data.IsPositional {
+			return name
+		}
+	}
+	return ""
+}
+
+// getArgString get the argument like "--path" or "-p" of the field named
+// name for error messages.
+func (ap *argParser) getArgString(name string) string {
+	var data = ap.ArgData[name]
+	if data.ShortCmd != "" {
+		return "\"--" + data.LongCmd + "\" or \"-" + data.ShortCmd + "\""
+	}
+	return "\"--" + data.LongCmd + "\""
+}
+
+// set set the value to the field named name, the error is saved and
+// returned by parse.
+func (ap *argParser) set(name, value string) {
+	var (
+		data  = ap.ArgData[name]
+		field = ap.Value.FieldByName(name)
+	)
+	if ap.givenArgs[name] && data.Type != ListArg {
+		ap.errMsgs = append(ap.errMsgs, "argument "+
+				ap.getArgString(name)+ " is duplicated")
+		return
+	}
+	switch data.Type {
+	case BoolArg:
+		var boolValue, err = strconv.ParseBool(value)
+		if err != nil {
+			ap.errMsgs = append(ap.errMsgs, "value of argument "+
+					ap.getArgString(name)+ " require true or false, \""+
+					value+ "\" is not")
+			return
+		}
+		field.SetBool(boolValue)
+	case IntArg:
+		var intValue, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			ap.errMsgs = append(ap.errMsgs, "value of argument "+
+					ap.getArgString(name)+ " require a number, \""+
+					value+ "\" is not")
+			return
+		}
+		field.SetInt(intValue)
+	case DurationArg:
+		if _, err := time.ParseDuration(value); err != nil {
+			ap.errMsgs = append(ap.errMsgs, "value of argument "+
+					ap.getArgString(name)+ " require a duration like "+
+					"\"1m30s\", \""+ value+ "\" is not")
+			return
+		}
+		field.SetString(value)
+	case ListArg:
+		// The first value replaces the value from config, and following
+		// values are appended to it.
+		if !ap.givenArgs[name] {
+			field.Set(reflect.ValueOf([]string(nil)))
+		}
+		field.Set(reflect.Append(field, reflect.ValueOf(value)))
+	default:
+		field.SetString(value)
+	}
+	ap.givenArgs[name] = true
+}
+
+// parse parse args and set values, options like "--path <folder>",
+// "--path=<folder>" or "-p <folder>" can be in any order, and other
+// arguments are values of the positional argument. If isGlobal is true,
+// parsing stops at the first argument that is not a option, which is the
+// command. It return the number of parsed arguments and the error of all
+// invalid arguments.
+func (ap *argParser) parse(args []string, isGlobal bool) (n int,
+		err error) {
+	var (
+		positionalName = ap.getPositionalName()
+		isValueOnly    = false
+	)
+	ap.givenArgs = make(map[string]bool)
+	ap.errMsgs = nil
+	
+	for ; n < len(args); n++ {
+		var (
+			arg      = args[n]
+			argName  string
+			value    string
+			hasValue bool
+		)
+		
+		// All arguments after "--" are values of the positional argument,
+		// and "-" means stdin so it is also a value.
+		if isGlobal && (!strings.HasPrefix(arg, "-") || arg == "-" ||
+				arg == "--") {
+			break
+		}
+		if arg == "--" && !isValueOnly {
+			isValueOnly = true
+			continue
+		}
+		if isValueOnly || !strings.HasPrefix(arg, "-") || arg == "-" {
+			if positionalName == "" {
+				ap.errMsgs = append(ap.errMsgs, "argument \""+ arg+
+						"\" is unexpected")
+			} else {
+				ap.set(positionalName, arg)
+			}
+			continue
+		}
+		
+		if i := strings.Index(arg, "="); i >= 0 {
+			arg, value, hasValue = arg[:i], arg[i+1:], true
+		}
+		if argName = ap.getArgName(arg); argName == "" {
+			ap.errMsgs = append(ap.errMsgs, "argument \""+ arg+
+					"\" not found")
+			continue
+		}
+		if !hasValue && ap.ArgData[argName].Type == BoolArg {
+			value, hasValue = "true", true
+		}
+		if !hasValue {
+			if n+1 >= len(args) {
+				ap.errMsgs = append(ap.errMsgs, "argument "+
+						ap.getArgString(argName)+ " require a value")
+				continue
+			}
+			n++
+			value = args[n]
+		}
+		ap.set(argName, value)
+	}
+	
+	// Required arguments may also be gotten from config.ini or environment
+	// variables before parsing.
+	var names []string
+	for name, data := range ap.ArgData {
+		if data.IsRequired && ap.Value.FieldByName(name).IsZero() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ap.errMsgs = append(ap.errMsgs, "argument "+
+				ap.getArgString(name)+ " is required")
+	}
+	
+	if len(ap.errMsgs) > 0 {
+		return n, &pixiv.AppError{Prefix: ap.Name, Msg: "invalid argument(s):\n\t" +
+				strings.Join(ap.errMsgs, ",\n\t")}
+	}
+	return n, nil
+}
+
+// getEnvName get the name of the environment variable of the field in the
+// section, like "PIXIV_CLIENT_MAX_CONNS_PER_HOST" of "MaxConnsPerHost" in
+// "Client".
+func getEnvName(section, field string) string {
+	var (
+		name  = []rune(section + "_" + field)
+		upper []rune
+	)
+	for i, r := range name {
+		// A word starts at a upper letter after a lower letter or a digit,
+		// or at the last upper letter before a lower letter like "Or" in
+		// "IDOrList".
+		if i > 0 && unicode.IsUpper(r) && (!unicode.IsUpper(name[i-1]) ||
+				(i+1 < len(name) && unicode.IsLower(name[i+1]))) &&
+				name[i-1] != '_' {
+			upper = append(upper, '_')
+		}
+		upper = append(upper, unicode.ToUpper(r))
+	}
+	return EnvPrefix + string(upper)
+}
+
+// loadEnv set values of environment variables to fields of the struct that
+// val point to, fields of embedded structs are in the same section, values
+// of slices of strings are split by spaces, and other fields that are not
+// bool, int, float or string are skipped.
+func loadEnv(section string, val reflect.Value) (errMsgs []string) {
+	for i := 0; i < val.NumField(); i++ {
+		var (
+			field      = val.Field(i)
+			fieldType  = val.Type().Field(i)
+			envName    = getEnvName(section, fieldType.Name)
+			value, has = os.LookupEnv(envName)
+			err        error
+		)
+		if fieldType.Anonymous && field.Kind() == reflect.Struct {
+			errMsgs = append(errMsgs, loadEnv(section, field)...)
+			continue
+		}
+		if !has || fieldType.PkgPath != "" {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.Bool:
+			var boolValue bool
+			if boolValue, err = strconv.ParseBool(value); err == nil {
+				field.SetBool(boolValue)
+			}
+		case reflect.Int:
+			var intValue int64
+			if intValue, err = strconv.ParseInt(value, 10, 64); err == nil {
+				field.SetInt(intValue)
+			}
+		case reflect.Float64:
+			var floatValue float64
+			if floatValue, err = strconv.ParseFloat(value, 64); err == nil {
+				field.SetFloat(floatValue)
+			}
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Slice:
+			if field.Type().Elem().Kind() == reflect.String {
+				field.Set(reflect.ValueOf(strings.Fields(value)))
+			}
+		}
+		if err != nil {
+			errMsgs = append(errMsgs, "value of environment variable \""+
+					envName+ "\" require a value of "+ field.Kind().String()+
+					", \""+ value+ "\" is not")
+		}
+	}
+	return errMsgs
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArgParserParse(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		args     []string
+		idOrList []string
+		path     string
+		errMsg   string
+	}{
+		{"Positional", []string{"100", "200", "-p", "out"},
+			[]string{"100", "200"}, "out", ""},
+		{"Equal", []string{"--path=out", "--id-or-list=100", "-i", "200"},
+			[]string{"100", "200"}, "out", ""},
+		{"Spaces", []string{"my list.txt", "-i", "other list.txt"},
+			[]string{"my list.txt", "other list.txt"}, "./", ""},
+		{"AfterDashes", []string{"-p", "out", "--", "-", "--path"},
+			[]string{"-", "--path"}, "out", ""},
+		{"Required", []string{"-p", "out"},
+			nil, "out", "\"--id-or-list\" or \"-i\" is required"},
+		{"NoValue", []string{"100", "--path"},
+			[]string{"100"}, "./", "\"--path\" or \"-p\" require a value"},
+		{"Unknown", []string{"100", "--works", "4"},
+			[]string{"100", "4"}, "./", "\"--works\" not found"},
+		{"Duplicated", []string{"100", "-p", "a", "--path", "b"},
+			[]string{"100"}, "a", "\"--path\" or \"-p\" is duplicated"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var p = &Pixiv{}
+			p.initCmdData()
+			p.initConfig()
+			var (
+				download = p.Config.Cmds["download"].(*Download)
+				parser   = &argParser{
+					Name:    "download",
+					ArgData: p.CmdData["download"].ArgData,
+					Value:   reflect.ValueOf(download).Elem(),
+				}
+				_, err = parser.parse(test.args, false)
+			)
+			if test.errMsg == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if test.errMsg != "" && (err == nil ||
+					!strings.Contains(err.Error(), test.errMsg)) {
+				t.Errorf("error %v does not contain %q", err, test.errMsg)
+			}
+			if !reflect.DeepEqual(download.IDOrList, test.idOrList) ||
+					download.Path != test.path {
+				t.Errorf("IDOrList is %q and Path is %q, not %q and %q",
+					download.IDOrList, download.Path, test.idOrList, test.path)
+			}
+		})
+	}
+}
+
+// TestArgParserParseGlobal check parsing global arguments stops at the
+// command, and values are checked by types.
+func TestArgParserParseGlobal(t *testing.T) {
+	var p = &Pixiv{}
+	p.initCmdData()
+	p.initConfig()
+	var (
+		parser = &argParser{
+			Name:    "pixiv",
+			ArgData: p.GlobalArgData,
+			Value:   reflect.ValueOf(p.Config.Client).Elem(),
+		}
+		n, err = parser.parse([]string{"--max-retries", "5",
+			"--retry-wait=2s", "download", "--path", "out"}, true)
+	)
+	if err != nil || n != 3 {
+		t.Fatalf("parsed %d arguments with error %v", n, err)
+	}
+	if p.Config.Client.MaxRetries != 5 || p.Config.Client.RetryWait != "2s" {
+		t.Errorf("MaxRetries is %d and RetryWait is %q",
+			p.Config.Client.MaxRetries, p.Config.Client.RetryWait)
+	}
+	
+	_, err = parser.parse([]string{"--max-retries", "x",
+		"--retry-wait", "2"}, true)
+	for _, errMsg := range []string{"require a number",
+		"require a duration"} {
+		if err == nil || !strings.Contains(err.Error(), errMsg) {
+			t.Errorf("error %v does not contain %q", err, errMsg)
+		}
+	}
+}
+
+// TestPixivLoadEnv check environment variables overwrite values of config.
+func TestPixivLoadEnv(t *testing.T) {
+	var p = &Pixiv{}
+	p.initCmdData()
+	p.initConfig()
+	t.Setenv("PIXIV_DOWNLOAD_PATH", "env")
+	t.Setenv("PIXIV_CLIENT_MAX_CONNS_PER_HOST", "8")
+	t.Setenv("PIXIV_LOGOUT_WILL_DELETE_COOKIE", "true")
+	t.Setenv("PIXIV_DOWNLOAD_ID_OR_LIST", " 100  200 ")
+	if err := p.loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if p.Config.Cmds["download"].(*Download).Path != "env" ||
+			!reflect.DeepEqual(p.Config.Cmds["download"].(*Download).IDOrList,
+				[]string{"100", "200"}) ||
+			p.Config.Client.MaxConnsPerHost != 8 ||
+			!p.Config.Cmds["logout"].(*Logout).WillDeleteCookie {
+		t.Errorf("values of environment variables are not loaded")
+	}
+	
+	t.Setenv("PIXIV_DOWNLOAD_WORKS", "many")
+	if err := p.loadEnv(); err == nil ||
+			!strings.Contains(err.Error(), "PIXIV_DOWNLOAD_WORKS") {
+		t.Errorf("error %v does not contain \"PIXIV_DOWNLOAD_WORKS\"", err)
+	}
+}
+
+func TestGetEnvName(t *testing.T) {
+	for _, test := range [][3]string{
+		{"Download", "IDOrList", "PIXIV_DOWNLOAD_ID_OR_LIST"},
+		{"Client", "MaxConnsPerHost", "PIXIV_CLIENT_MAX_CONNS_PER_HOST"},
+		{"Client", "HAR", "PIXIV_CLIENT_HAR"},
+	} {
+		if name := getEnvName(test[0], test[1]); name != test[2] {
+			t.Errorf("name of %s.%s is %q, not %q",
+				test[0], test[1], name, test[2])
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	
+	"github.com/abc1236762/pixiv_tool/pixiv"
+)
+
+// ClientSection is the section of Client in config.ini.
+const ClientSection = "Client"
+
+// cmdRegistry save commands registered by RegisterCmd, it is copied to
+// Pixiv.CmdData when Pixiv is initialized.
+var cmdRegistry = make(map[string]CmdData)
+
+// A CmdData save data of a command about this app. New make the doer of
+// the command with default values, its fields are options that are loaded
+// from Section of config.ini and environment variables, and fields in
+// ArgData can also be given by arguments. The doer must be a pointer to a
+// struct with a field "Client *pixiv.Client" that is set before running.
+type CmdData struct {
+	Cmd     string
+	Help    string
+	Section string
+	New     func() Doer
+	ArgData map[string]ArgData
+}
+
+// A ArgData save data of arguments of each command about this app.
+// Arguments that are not options are values of the argument with
+// IsPositional, each command can have only one of it. Value is the name
+// of the value in help, folders or files are completed by shells if it is
+// FolderValue or FileValue.
+type ArgData struct {
+	LongCmd      string
+	ShortCmd     string
+	Type         ArgType
+	Value        string
+	Help         string
+	IsRequired   bool
+	IsPositional bool
+}
+
+// A configChecker is a doer that check its values after config.ini and
+// environment variables are loaded, so invalid values are found before
+// running.
+type configChecker interface {
+	checkConfig() error
+}
+
+// RegisterCmd register a command, it is called in init of the file of the
+// command so the command can be added without changing Pixiv. The command
+// is checked by checkCmdData when Pixiv run, and RegisterCmd panics if a
+// command with the same name is registered.
+func RegisterCmd(cmdData CmdData) {
+	if _, isExist := cmdRegistry[cmdData.Cmd]; isExist {
+		panic("pixiv: command \"" + cmdData.Cmd + "\" is registered twice")
+	}
+	cmdRegistry[cmdData.Cmd] = cmdData
+}
+
+// checkArgData check arguments in argData can be set to fields of the
+// struct typ, errMsgs are prefixed by prefix.
+func checkArgData(prefix string, argData map[string]ArgData,
+		typ reflect.Type) (errMsgs []string) {
+	var (
+		names           []string
+		usedArgs        = make(map[string]string)
+		positionalCount int
+	)
+	for name := range argData {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	
+	for _, name := range names {
+		var (
+			data           = argData[name]
+			field, isExist = typ.FieldByName(name)
+			argPrefix      = prefix + "argument \"" + name + "\" "
+			longCmd        = "--" + data.LongCmd
+			shortCmd       = "-" + data.ShortCmd
+		)
+		if !isExist || field.PkgPath != "" {
+			errMsgs = append(errMsgs, argPrefix+
+					"is not an exported field")
+		} else if field.Type.Kind() != data.Type.Kind() {
+			errMsgs = append(errMsgs, argPrefix+ "should be a field of \""+
+					data.Type.Kind().String()+ "\"")
+		} else if data.Type == ListArg &&
+				field.Type.Elem().Kind() != reflect.String {
+			errMsgs = append(errMsgs, argPrefix+
+					"should be a field of \"[]string\"")
+		}
+		if data.LongCmd == "" {
+			errMsgs = append(errMsgs, argPrefix+ "does not have LongCmd")
+		}
+		
+		// "--help" and "-h" are used to show help of every command.
+		if isHelpArg(longCmd) ||
+				(data.ShortCmd != "" && isHelpArg(shortCmd)) {
+			errMsgs = append(errMsgs, argPrefix+
+					"should not be \"--help\" or \"-h\"")
+		}
+		for _, arg := range []string{longCmd, shortCmd} {
+			if usedName, isUsed := usedArgs[arg]; isUsed {
+				errMsgs = append(errMsgs, argPrefix+ "use \""+ arg+
+						"\" of argument \""+ usedName+ "\"")
+			} else if arg != "-" {
+				usedArgs[arg] = name
+			}
+		}
+		if data.IsPositional {
+			positionalCount++
+		}
+	}
+	if positionalCount > 1 {
+		errMsgs = append(errMsgs, prefix+
+				"have more than one positional argument")
+	}
+	return errMsgs
+}
+
+// checkCmdData check the command can be run by Pixiv or not, errMsgs are
+// problems of it.
+func checkCmdData(cmdData CmdData) (errMsgs []string) {
+	var prefix = "command \"" + cmdData.Cmd + "\" "
+	if cmdData.Cmd == "" || strings.HasPrefix(cmdData.Cmd, "-") ||
+			cmdData.Cmd == HelpCmd || cmdData.Cmd == CompletionCmd {
+		errMsgs = append(errMsgs, prefix+ "should not be empty, "+
+				"an option or a builtin command")
+	}
+	if cmdData.Section == "" || cmdData.Section == ClientSection {
+		errMsgs = append(errMsgs, prefix+ "should have a section that is "+
+				"not \""+ ClientSection+ "\"")
+	}
+	if cmdData.New == nil {
+		return append(errMsgs, prefix+ "does not have New")
+	}
+	
+	var (
+		doer    = cmdData.New()
+		doerVal = reflect.ValueOf(doer)
+	)
+	if doerVal.Kind() != reflect.Ptr ||
+			doerVal.Elem().Kind() != reflect.Struct {
+		return append(errMsgs, prefix+ "should make a pointer to a struct")
+	}
+	if field, isExist := doerVal.Elem().Type().FieldByName(
+		"Client"); !isExist || field.Type != reflect.TypeOf(&pixiv.Client{}) {
+		errMsgs = append(errMsgs, prefix+
+				"should have a field \"Client *pixiv.Client\"")
+	}
+	errMsgs = append(errMsgs, checkArgData(prefix, cmdData.ArgData,
+		doerVal.Elem().Type())...)
+	
+	// A required argument must be given, so it should not have a default
+	// value.
+	for name, data := range cmdData.ArgData {
+		var field = doerVal.Elem().FieldByName(name)
+		if data.IsRequired && field.IsValid() && !field.IsZero() {
+			errMsgs = append(errMsgs, prefix+ "argument \""+ name+
+					"\" is required but has a default value")
+		}
+	}
+	return errMsgs
+}
+
+// checkCmdData check commands in Pixiv.CmdData and global arguments in
+// Pixiv.GlobalArgData.
+func (p *Pixiv) checkCmdData() error {
+	var (
+		errMsgs  []string
+		sections = make(map[string]string)
+	)
+	for _, cmdData := range p.getSortedCmdData() {
+		errMsgs = append(errMsgs, checkCmdData(cmdData)...)
+		if cmd, isUsed := sections[cmdData.Section]; isUsed {
+			errMsgs = append(errMsgs, "command \""+ cmdData.Cmd+
+					"\" use the section of command \""+ cmd+ "\"")
+		}
+		sections[cmdData.Section] = cmdData.Cmd
+	}
+	
+	// Global arguments are set to fields of Client, they are only options.
+	errMsgs = append(errMsgs, checkArgData("global ", p.GlobalArgData,
+		reflect.TypeOf(pixiv.Client{}))...)
+	for name, argData := range p.GlobalArgData {
+		if argData.IsRequired || argData.IsPositional {
+			errMsgs = append(errMsgs, "global argument \""+ name+
+					"\" should not be required or positional")
+		}
+	}
+	
+	if len(errMsgs) > 0 {
+		return throw(p, "error(s) in commands:\n\t"+
+				strings.Join(errMsgs, ",\n\t"))
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	
+	"github.com/abc1236762/pixiv_tool/pixiv"
+)
+
+// A fakeCmd is a command for checking CmdData.
+type fakeCmd struct {
+	Client *pixiv.Client
+	Name   string
+	Count  int
+	Force  bool
+	Tags   []string
+	IDs    []int
+}
+
+// Do is needed when implement a Doer interface.
+func (fc *fakeCmd) Do(context.Context) error { return nil }
+
+// A fakeCmdWithoutClient is a command without the field "Client".
+type fakeCmdWithoutClient struct {
+	Name string
+}
+
+// Do is needed when implement a Doer interface.
+func (fc *fakeCmdWithoutClient) Do(context.Context) error { return nil }
+
+// TestPixivCheckCmdData check registered commands are valid.
+func TestPixivCheckCmdData(t *testing.T) {
+	var p = &Pixiv{}
+	p.initCmdData()
+	if err := p.checkCmdData(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckCmdData(t *testing.T) {
+	var newFakeCmd = func() Doer { return &fakeCmd{Name: "default"} }
+	for _, test := range []struct {
+		name    string
+		cmdData CmdData
+		errMsg  string
+	}{
+		{"Valid", CmdData{Cmd: "fake", Section: "Fake", New: newFakeCmd,
+			ArgData: map[string]ArgData{
+				"Count": {LongCmd: "count", ShortCmd: "c", Type: IntArg},
+				"Force": {LongCmd: "force", Type: BoolArg},
+				"Tags":  {LongCmd: "tag", Type: ListArg},
+			}}, ""},
+		{"Builtin", CmdData{Cmd: HelpCmd, Section: "Fake", New: newFakeCmd},
+			"builtin command"},
+		{"NoSection", CmdData{Cmd: "fake", New: newFakeCmd},
+			"should have a section"},
+		{"NoNew", CmdData{Cmd: "fake", Section: "Fake"},
+			"does not have New"},
+		{"NoClient", CmdData{Cmd: "fake", Section: "Fake",
+			New: func() Doer { return &fakeCmdWithoutClient{} }},
+			"should have a field \"Client *pixiv.Client\""},
+		{"NoField", CmdData{Cmd: "fake", Section: "Fake", New: newFakeCmd,
+			ArgData: map[string]ArgData{
+				"Size": {LongCmd: "size", Type: IntArg},
+			}}, "is not an exported field"},
+		{"Kind", CmdData{Cmd: "fake", Section: "Fake", New: newFakeCmd,
+			ArgData: map[string]ArgData{
+				"Count": {LongCmd: "count", Type: StringArg},
+			}}, "should be a field of \"string\""},
+		{"ListKind", CmdData{Cmd: "fake", Section: "Fake", New: newFakeCmd,
+			ArgData: map[string]ArgData{
+				"Name": {LongCmd: "name", Type: ListArg},
+			}}, "should be a field of \"slice\""},
+		{"ListElem", CmdData{Cmd: "fake", Section: "Fake", New: newFakeCmd,
+			ArgData: map[string]ArgData{
+				"IDs": {LongCmd: "id", Type: ListArg},
+			}}, "should be a field of \"[]string\""},
+		{"Help", CmdData{Cmd: "fake", Section: "Fake", New: newFakeCmd,
+			ArgData: map[string]ArgData{
+				"Force": {LongCmd: "force", ShortCmd: "h", Type: BoolArg},
+			}}, "should not be \"--help\" or \"-h\""},
+		{"Duplicated", CmdData{Cmd: "fake", Section: "Fake", New: newFakeCmd,
+			ArgData: map[string]ArgData{
+				"Count": {LongCmd: "count", ShortCmd: "c", Type: IntArg},
+				"Name":  {LongCmd: "name", ShortCmd: "c", Type: StringArg},
+			}}, "use \"-c\" of argument \"Count\""},
+		{"Positional", CmdData{Cmd: "fake", Section: "Fake", New: newFakeCmd,
+			ArgData: map[string]ArgData{
+				"Count": {LongCmd: "count", Type: IntArg, IsPositional: true},
+				"Name": {LongCmd: "name", Type: StringArg,
+					IsPositional: true},
+			}}, "more than one positional argument"},
+		{"RequiredDefault", CmdData{Cmd: "fake", Section: "Fake",
+			New: newFakeCmd, ArgData: map[string]ArgData{
+				"Name": {LongCmd: "name", Type: StringArg, IsRequired: true},
+			}}, "is required but has a default value"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var errMsgs = strings.Join(checkCmdData(test.cmdData), "\n")
+			if test.errMsg == "" && errMsgs != "" {
+				t.Errorf("unexpected error: %s", errMsgs)
+			} else if !strings.Contains(errMsgs, test.errMsg) {
+				t.Errorf("errors %q do not contain %q", errMsgs, test.errMsg)
+			}
+		})
+	}
+}
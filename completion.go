@@ -0,0 +1,355 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Names of values of arguments that are completed by shells, values of
+// IDOrFileValue are work IDs or files and only files are completed.
+const (
+	FolderValue   = "folder"
+	FileValue     = "file"
+	IDOrFileValue = "id-or-file"
+)
+
+// completionShells is shells that completion scripts can be written for.
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// A cmdCompletion is a command with its arguments for completion.
+type cmdCompletion struct {
+	CmdData
+	ArgHelps []argHelp
+	// Positional is the argument with ArgData.IsPositional, it is nil if
+	// the command does not have it.
+	Positional *argHelp
+	IsBuiltin  bool
+}
+
+// isFile check the value of argument is completed by files or not.
+func (ah argHelp) isFile() bool {
+	return ah.Value == FileValue || ah.Value == IDOrFileValue
+}
+
+// getCmdCompletions get commands sorted by names with their arguments,
+// builtin commands are at last.
+func (p *Pixiv) getCmdCompletions() (cmdCompletions []cmdCompletion) {
+	for _, cmdData := range p.getSortedCmdData() {
+		var item = cmdCompletion{CmdData: cmdData,
+			ArgHelps: getArgHelps(cmdData.ArgData, p.getCmdVal(cmdData))}
+		for i := range item.ArgHelps {
+			if item.ArgHelps[i].IsPositional {
+				item.Positional = &item.ArgHelps[i]
+			}
+		}
+		cmdCompletions = append(cmdCompletions, item)
+	}
+	for _, cmdData := range builtinCmdData {
+		cmdCompletions = append(cmdCompletions, cmdCompletion{
+			CmdData: cmdData, IsBuiltin: true})
+	}
+	return cmdCompletions
+}
+
+// getBuiltinValues get values of the builtin command for completion, they
+// are names of commands of "help" and shells of "completion".
+func (p *Pixiv) getBuiltinValues(cmd string) (values []string) {
+	if cmd == CompletionCmd {
+		return completionShells
+	}
+	for _, cmdData := range p.getSortedCmdData() {
+		values = append(values, cmdData.Cmd)
+	}
+	return append(values, "--man")
+}
+
+// writeBashValueCase write the case of bash that complete values of
+// arguments after them.
+func writeBashValueCase(w io.Writer, argHelps []argHelp, indent string) {
+	var folderArgs, fileArgs, otherArgs []string
+	for _, item := range argHelps {
+		switch {
+		case item.Type == BoolArg:
+		case item.Value == FolderValue:
+			folderArgs = append(folderArgs, item.getNames()...)
+		case item.isFile():
+			fileArgs = append(fileArgs, item.getNames()...)
+		default:
+			otherArgs = append(otherArgs, item.getNames()...)
+		}
+	}
+	fmt.Fprintf(w, "%scase \"$prev\" in\n", indent)
+	for _, c := range []struct {
+		args  []string
+		reply string
+	}{
+		{folderArgs, `COMPREPLY=($(compgen -d -- "$cur"))`},
+		{fileArgs, `COMPREPLY=($(compgen -f -- "$cur"))`},
+		{otherArgs, `COMPREPLY=()`},
+	} {
+		if len(c.args) > 0 {
+			fmt.Fprintf(w, "%s%s) %s; return ;;\n", indent,
+				strings.Join(c.args, "|"), c.reply)
+		}
+	}
+	fmt.Fprintf(w, "%sesac\n", indent)
+}
+
+// getBashWords get all names of arguments split by spaces.
+func getBashWords(argHelps []argHelp) string {
+	var words []string
+	for _, item := range argHelps {
+		words = append(words, item.getNames()...)
+	}
+	return strings.Join(words, " ")
+}
+
+// writeBashCompletion write the completion script of bash.
+func (p *Pixiv) writeBashCompletion(w io.Writer) {
+	var (
+		globalArgHelps = getArgHelps(p.GlobalArgData,
+			reflect.ValueOf(p.Config.Client).Elem())
+		cmds, valueArgs []string
+		funcName        = "_" + AppName
+	)
+	for _, item := range globalArgHelps {
+		if item.Type != BoolArg {
+			valueArgs = append(valueArgs, item.getNames()...)
+		}
+	}
+	for _, cmdCompletion := range p.getCmdCompletions() {
+		cmds = append(cmds, cmdCompletion.Cmd)
+	}
+	
+	fmt.Fprintf(w, "# bash completion of %s, generated by \"%s completion "+
+			"bash\".\n", AppName, AppName)
+	fmt.Fprintf(w, "%s() {\n", funcName)
+	fmt.Fprintf(w, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\" "+
+			"prev=\"${COMP_WORDS[COMP_CWORD-1]}\" cmd i\n")
+	fmt.Fprintf(w, "    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	fmt.Fprintf(w, "        case \"${COMP_WORDS[i]}\" in\n")
+	if len(valueArgs) > 0 {
+		fmt.Fprintf(w, "        %s) ((i++)) ;;\n",
+			strings.Join(valueArgs, "|"))
+	}
+	fmt.Fprintf(w, "        -*) ;;\n")
+	fmt.Fprintf(w, "        *) cmd=\"${COMP_WORDS[i]}\"; break ;;\n")
+	fmt.Fprintf(w, "        esac\n")
+	fmt.Fprintf(w, "    done\n")
+	fmt.Fprintf(w, "    case \"$cmd\" in\n")
+	
+	fmt.Fprintf(w, "    \"\")\n")
+	writeBashValueCase(w, globalArgHelps, "        ")
+	fmt.Fprintf(w, "        if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(w, "            COMPREPLY=($(compgen -W \"%s\" -- "+
+			"\"$cur\"))\n", getBashWords(globalArgHelps))
+	fmt.Fprintf(w, "        else\n")
+	fmt.Fprintf(w, "            COMPREPLY=($(compgen -W \"%s\" -- "+
+			"\"$cur\"))\n", strings.Join(cmds, " "))
+	fmt.Fprintf(w, "        fi ;;\n")
+	
+	for _, cmdCompletion := range p.getCmdCompletions() {
+		fmt.Fprintf(w, "    %s)\n", cmdCompletion.Cmd)
+		if cmdCompletion.IsBuiltin {
+			// Builtin commands only have values like names of commands.
+			fmt.Fprintf(w, "        COMPREPLY=($(compgen -W \"%s\" -- "+
+					"\"$cur\")) ;;\n", strings.Join(
+				p.getBuiltinValues(cmdCompletion.Cmd), " "))
+			continue
+		}
+		writeBashValueCase(w, cmdCompletion.ArgHelps, "        ")
+		fmt.Fprintf(w, "        if [[ \"$cur\" == -* ]]; then\n")
+		fmt.Fprintf(w, "            COMPREPLY=($(compgen -W \"%s\" -- "+
+				"\"$cur\"))\n", getBashWords(cmdCompletion.ArgHelps))
+		if cmdCompletion.Positional != nil &&
+				cmdCompletion.Positional.isFile() {
+			fmt.Fprintf(w, "        else\n")
+			fmt.Fprintf(w, "            COMPREPLY=($(compgen -f -- "+
+					"\"$cur\"))\n")
+		}
+		fmt.Fprintf(w, "        fi ;;\n")
+	}
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "complete -o filenames -F %s %s\n", funcName, AppName)
+}
+
+// escapeZsh escape text to be in the description of "_arguments" of zsh
+// in single quotes.
+func escapeZsh(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `'\''`, "[", `\[`,
+		"]", `\]`, ":", `\:`).Replace(text)
+}
+
+// getZshSpec get the spec of argument for "_arguments" of zsh like
+// "'(-p --path)'{-p,--path}'[help]:folder:_files -/'".
+func getZshSpec(item argHelp) (spec string) {
+	var names = item.getNames()
+	if item.Type == ListArg {
+		spec = "'*'"
+	} else if len(names) > 1 {
+		spec = "'(" + strings.Join(names, " ") + ")'"
+	}
+	if len(names) > 1 {
+		spec += "{" + strings.Join(names, ",") + "}"
+	} else {
+		spec += names[0]
+	}
+	spec += "'[" + escapeZsh(item.getHelp()) + "]"
+	switch {
+	case item.Type == BoolArg:
+	case item.Value == FolderValue:
+		spec += ":" + escapeZsh(item.getValueName()) + ":_files -/"
+	case item.isFile():
+		spec += ":" + escapeZsh(item.getValueName()) + ":_files"
+	default:
+		spec += ":" + escapeZsh(item.getValueName()) + ": "
+	}
+	return spec + "'"
+}
+
+// writeZshArguments write "_arguments" of zsh with specs.
+func writeZshArguments(w io.Writer, indent string, specs []string) {
+	fmt.Fprintf(w, "%s_arguments", indent)
+	for _, spec := range specs {
+		fmt.Fprintf(w, " \\\n%s    %s", indent, spec)
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// writeZshCompletion write the completion script of zsh.
+func (p *Pixiv) writeZshCompletion(w io.Writer) {
+	var (
+		funcName = "_" + AppName
+		specs    []string
+	)
+	fmt.Fprintf(w, "#compdef %s\n\n", AppName)
+	fmt.Fprintf(w, "# zsh completion of %s, generated by \"%s completion "+
+			"zsh\".\n", AppName, AppName)
+	fmt.Fprintf(w, "%s() {\n", funcName)
+	fmt.Fprintf(w, "    local -a commands\n")
+	fmt.Fprintf(w, "    local state\n")
+	fmt.Fprintf(w, "    commands=(\n")
+	for _, cmdCompletion := range p.getCmdCompletions() {
+		fmt.Fprintf(w, "        '%s:%s'\n", cmdCompletion.Cmd,
+			escapeZsh(cmdCompletion.Help))
+	}
+	fmt.Fprintf(w, "    )\n")
+	for _, item := range getArgHelps(p.GlobalArgData,
+		reflect.ValueOf(p.Config.Client).Elem()) {
+		specs = append(specs, getZshSpec(item))
+	}
+	specs = append(specs, "'1:command:->command'",
+		"'*::argument:->argument'")
+	writeZshArguments(w, "    ", append([]string{"-C"}, specs...))
+	fmt.Fprintf(w, "    case $state in\n")
+	fmt.Fprintf(w, "    command) _describe 'command' commands ;;\n")
+	fmt.Fprintf(w, "    argument)\n")
+	fmt.Fprintf(w, "        case $words[1] in\n")
+	for _, cmdCompletion := range p.getCmdCompletions() {
+		specs = nil
+		fmt.Fprintf(w, "        %s)\n", cmdCompletion.Cmd)
+		if cmdCompletion.IsBuiltin {
+			specs = append(specs, "'*:value:("+ strings.Join(
+				p.getBuiltinValues(cmdCompletion.Cmd), " ")+ ")'")
+		} else {
+			for _, item := range cmdCompletion.ArgHelps {
+				specs = append(specs, getZshSpec(item))
+			}
+			if cmdCompletion.Positional != nil &&
+					cmdCompletion.Positional.isFile() {
+				specs = append(specs, "'*:"+ escapeZsh(
+					cmdCompletion.Positional.getValueName())+ ":_files'")
+			}
+		}
+		writeZshArguments(w, "            ", specs)
+		fmt.Fprintf(w, "            ;;\n")
+	}
+	fmt.Fprintf(w, "        esac ;;\n")
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "%s \"$@\"\n", funcName)
+}
+
+// escapeFish escape text to be in single quotes of fish.
+func escapeFish(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(text)
+}
+
+// writeFishArgs write completions of arguments of fish under the
+// condition.
+func writeFishArgs(w io.Writer, condition string, argHelps []argHelp) {
+	for _, item := range argHelps {
+		fmt.Fprintf(w, "complete -c %s -n '%s'", AppName, condition)
+		if item.ShortCmd != "" {
+			fmt.Fprintf(w, " -s %s", item.ShortCmd)
+		}
+		fmt.Fprintf(w, " -l %s", item.LongCmd)
+		switch {
+		case item.Type == BoolArg:
+		case item.Value == FolderValue:
+			fmt.Fprintf(w, " -x -a '(__fish_complete_directories "+
+					"(commandline -ct))'")
+		case item.isFile():
+			fmt.Fprintf(w, " -r -F")
+		default:
+			fmt.Fprintf(w, " -x")
+		}
+		fmt.Fprintf(w, " -d '%s'\n", escapeFish(item.getHelp()))
+	}
+}
+
+// writeFishCompletion write the completion script of fish.
+func (p *Pixiv) writeFishCompletion(w io.Writer) {
+	fmt.Fprintf(w, "# fish completion of %s, generated by \"%s completion "+
+			"fish\".\n", AppName, AppName)
+	fmt.Fprintf(w, "complete -c %s -f\n", AppName)
+	for _, cmdCompletion := range p.getCmdCompletions() {
+		fmt.Fprintf(w, "complete -c %s -n __fish_use_subcommand -a %s "+
+				"-d '%s'\n", AppName, cmdCompletion.Cmd,
+			escapeFish(cmdCompletion.Help))
+	}
+	writeFishArgs(w, "__fish_use_subcommand", getArgHelps(p.GlobalArgData,
+		reflect.ValueOf(p.Config.Client).Elem()))
+	for _, cmdCompletion := range p.getCmdCompletions() {
+		var condition = "__fish_seen_subcommand_from " + cmdCompletion.Cmd
+		if cmdCompletion.IsBuiltin {
+			fmt.Fprintf(w, "complete -c %s -n '%s' -a '%s'\n", AppName,
+				condition, strings.Join(
+					p.getBuiltinValues(cmdCompletion.Cmd), " "))
+			continue
+		}
+		writeFishArgs(w, condition, cmdCompletion.ArgHelps)
+		if cmdCompletion.Positional != nil &&
+				cmdCompletion.Positional.isFile() {
+			fmt.Fprintf(w, "complete -c %s -n '%s' -F\n", AppName, condition)
+		}
+	}
+}
+
+// doCompletion write the completion script of the shell to w if it is
+// asked by "completion <shell>", isCompletion is false if it is not asked.
+func (p *Pixiv) doCompletion(w io.Writer) (isCompletion bool, err error) {
+	var cmdIndex = p.getCmdIndex()
+	if cmdIndex == len(p.args) || p.args[cmdIndex] != CompletionCmd {
+		return false, nil
+	}
+	var args = p.args[cmdIndex+1:]
+	if len(args) != 1 {
+		return true, throw(p, "command \""+ CompletionCmd+ "\" require a shell of \""+
+				strings.Join(completionShells, "\", \"")+ "\"")
+	}
+	switch args[0] {
+	case "bash":
+		p.writeBashCompletion(w)
+	case "zsh":
+		p.writeZshCompletion(w)
+	case "fish":
+		p.writeFishCompletion(w)
+	default:
+		return true, throw(p, "shell \""+ args[0]+ "\" is not supported")
+	}
+	return true, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+	
+	"github.com/abc1236762/pixiv_tool/internal/golden"
+)
+
+func TestPixivDoCompletion(t *testing.T) {
+	for _, shell := range completionShells {
+		t.Run(shell, func(t *testing.T) {
+			var (
+				p   = &Pixiv{args: []string{CompletionCmd, shell}}
+				buf bytes.Buffer
+				g   golden.File
+			)
+			p.initCmdData()
+			p.initConfig()
+			var isCompletion, err = p.doCompletion(&buf)
+			if !isCompletion || err != nil {
+				t.Fatalf("isCompletion: %v, %v", isCompletion, err)
+			}
+			g.Section("script", buf.String())
+			g.Check(t)
+			
+			// Check syntax of the script if the shell is installed.
+			if _, err = exec.LookPath(shell); err != nil {
+				return
+			}
+			var cmd = exec.Command(shell, "-n")
+			cmd.Stdin = &buf
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("%v: %s", err, out)
+			}
+		})
+	}
+}
+
+func TestPixivDoCompletionInvalid(t *testing.T) {
+	for _, args := range []string{"completion", "completion tcsh",
+		"completion bash zsh"} {
+		var p = &Pixiv{args: strings.Fields(args)}
+		p.initCmdData()
+		p.initConfig()
+		if isCompletion, err := p.doCompletion(
+			&bytes.Buffer{}); !isCompletion || err == nil {
+			t.Errorf("%q is not an error", args)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// AppDescription is the description of this app in help and man pages.
+	AppDescription = "download works from Pixiv"
+	// HelpWidth is the width of help, and HelpIndent is the width of
+	// options before their help.
+	HelpWidth  = 80
+	HelpIndent = 26
+	// HelpCmd and CompletionCmd are names of builtin commands.
+	HelpCmd       = "help"
+	CompletionCmd = "completion"
+)
+
+// helpArgData is the argument that show help of this app or a command,
+// every command have it.
+var helpArgData = ArgData{
+	LongCmd:  "help",
+	ShortCmd: "h",
+	Type:     BoolArg,
+	Help:     "show this help",
+}
+
+// builtinCmdData is commands that are not in Pixiv.CmdData, they are done
+// before loading config.
+var builtinCmdData = []CmdData{
+	{
+		Cmd:  HelpCmd,
+		Help: "Show help of this app or a command, or man pages with \"--man\"",
+	},
+	{
+		Cmd:  CompletionCmd,
+		Help: "Write the completion script of bash, zsh or fish",
+	},
+}
+
+// An argHelp is an argument with its help for printing.
+type argHelp struct {
+	ArgData
+	Default string
+}
+
+// isHelpArg check the argument is "--help" or "-h" or not.
+func isHelpArg(arg string) bool {
+	return arg == "--"+helpArgData.LongCmd || arg == "-"+helpArgData.ShortCmd
+}
+
+// getCmdIndex get the index of the command in Pixiv.args by skipping
+// global arguments and their values, it is the length of Pixiv.args if
+// there is no command.
+func (p *Pixiv) getCmdIndex() (i int) {
+	var parser = &argParser{ArgData: p.GlobalArgData}
+	for i < len(p.args) && strings.HasPrefix(p.args[i], "-") &&
+			p.args[i] != "-" && p.args[i] != "--" {
+		var name = parser.getArgName(p.args[i])
+		if name != "" && p.GlobalArgData[name].Type != BoolArg {
+			i++
+		}
+		i++
+	}
+	if i > len(p.args) {
+		// The last global argument does not have a value.
+		i = len(p.args)
+	}
+	return i
+}
+
+// getSortedCmdData get data of all commands sorted by names.
+func (p *Pixiv) getSortedCmdData() (cmdData []CmdData) {
+	for _, data := range p.CmdData {
+		cmdData = append(cmdData, data)
+	}
+	sort.Slice(cmdData, func(i, j int) bool {
+		return cmdData[i].Cmd < cmdData[j].Cmd
+	})
+	return cmdData
+}
+
+// getArgHelps get arguments sorted by long names with default values in
+// the struct val, and the argument of help at last.
+func getArgHelps(argData map[string]ArgData,
+		val reflect.Value) (argHelps []argHelp) {
+	for name, data := range argData {
+		var item = argHelp{ArgData: data}
+		if field := val.FieldByName(name); field.Kind() == reflect.Slice {
+			item.Default = strings.Join(field.Interface().([]string), " ")
+		} else if !field.IsZero() {
+			item.Default = fmt.Sprint(field.Interface())
+		}
+		argHelps = append(argHelps, item)
+	}
+	sort.Slice(argHelps, func(i, j int) bool {
+		return argHelps[i].LongCmd < argHelps[j].LongCmd
+	})
+	return append(argHelps, argHelp{ArgData: helpArgData})
+}
+
+// getCmdVal get the struct of the command in Pixiv.Config, its values are
+// defaults before config.ini is loaded.
+func (p *Pixiv) getCmdVal(cmdData CmdData) reflect.Value {
+	return reflect.ValueOf(p.Config.Cmds[cmdData.Cmd]).Elem()
+}
+
+// getUsage get the usage line of the command, or this app if cmdData is
+// nil.
+func getUsage(cmdData *CmdData) string {
+	var usage = AppName + " [global options] "
+	if cmdData == nil {
+		return usage + "<command> [options]"
+	}
+	usage += cmdData.Cmd + " [options]"
+	for _, data := range cmdData.ArgData {
+		if data.IsPositional {
+			usage += " [<" + data.LongCmd + ">...]"
+		}
+	}
+	return usage
+}
+
+// getValueName get the name of the value of argument like "folder" in
+// help, it is the name of the type if ArgData.Value is empty.
+func (ah argHelp) getValueName() string {
+	if ah.Value != "" {
+		return ah.Value
+	}
+	return ah.Type.String()
+}
+
+// getNames get the argument like "-p" and "--path" of the argument.
+func (ah argHelp) getNames() (names []string) {
+	if ah.ShortCmd != "" {
+		names = append(names, "-"+ah.ShortCmd)
+	}
+	return append(names, "--"+ah.LongCmd)
+}
+
+// getName get the argument like "-p, --path <folder>" in help.
+func (ah argHelp) getName() (name string) {
+	name = strings.Join(ah.getNames(), ", ")
+	if ah.Type != BoolArg {
+		name += " <" + ah.getValueName() + ">"
+	}
+	return name
+}
+
+// getHelp get the help of argument with markers of required and the
+// default value.
+func (ah argHelp) getHelp() (help string) {
+	help = ah.Help
+	if ah.IsRequired {
+		help += " (required)"
+	}
+	if ah.Default != "" {
+		help += " (default: " + ah.Default + ")"
+	}
+	return help
+}
+
+// wrapText split text into lines that are not longer than width, a word
+// longer than width is not split.
+func wrapText(text string, width int) (lines []string) {
+	var line string
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	return append(lines, line)
+}
+
+// writeItems write names and their helps in two columns, the help is
+// written from the next line if the name is too long.
+func writeItems(w io.Writer, names, helps []string) {
+	for i := range names {
+		var lines = wrapText(helps[i], HelpWidth-HelpIndent)
+		if len(names[i])+4 > HelpIndent {
+			fmt.Fprintf(w, "  %s\n", names[i])
+		} else {
+			fmt.Fprintf(w, "  %-*s%s\n", HelpIndent-2, names[i], lines[0])
+			lines = lines[1:]
+		}
+		for _, line := range lines {
+			fmt.Fprintf(w, "%*s%s\n", HelpIndent, "", line)
+		}
+	}
+}
+
+// writeArgs write arguments and their helps.
+func writeArgs(w io.Writer, argHelps []argHelp) {
+	var names, helps []string
+	for _, item := range argHelps {
+		names = append(names, item.getName())
+		helps = append(helps, item.getHelp())
+	}
+	writeItems(w, names, helps)
+}
+
+// writeUsage write the usage of this app with commands and global
+// arguments.
+func (p *Pixiv) writeUsage(w io.Writer) {
+	var names, helps []string
+	fmt.Fprintf(w, "Usage: %s\n\n", getUsage(nil))
+	fmt.Fprintf(w, "Commands:\n")
+	for _, cmdData := range p.getSortedCmdData() {
+		names = append(names, cmdData.Cmd)
+		helps = append(helps, cmdData.Help)
+	}
+	for _, cmdData := range builtinCmdData {
+		names = append(names, cmdData.Cmd)
+		helps = append(helps, cmdData.Help)
+	}
+	writeItems(w, names, helps)
+	fmt.Fprintf(w, "\nGlobal options:\n")
+	writeArgs(w, getArgHelps(p.GlobalArgData,
+		reflect.ValueOf(p.Config.Client).Elem()))
+	fmt.Fprintf(w, "\nRun \"%s help <command>\" or \"%s <command> --help\"\n"+
+			"for help of a command.\n", AppName, AppName)
+}
+
+// writeCmdHelp write the usage of the command with its arguments.
+func (p *Pixiv) writeCmdHelp(w io.Writer, cmdData CmdData) {
+	fmt.Fprintf(w, "Usage: %s\n\n", getUsage(&cmdData))
+	fmt.Fprintf(w, "%s\n\n", cmdData.Help)
+	fmt.Fprintf(w, "Options:\n")
+	writeArgs(w, getArgHelps(cmdData.ArgData, p.getCmdVal(cmdData)))
+}
+
+// escapeRoff escape text to be written in roff.
+func escapeRoff(text string) string {
+	text = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(text)
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+		text = `\&` + text
+	}
+	return text
+}
+
+// writeManArgs write arguments and their helps in roff.
+func writeManArgs(w io.Writer, argHelps []argHelp) {
+	for _, item := range argHelps {
+		fmt.Fprintf(w, ".TP\n")
+		if item.ShortCmd != "" {
+			fmt.Fprintf(w, `\fB\-%s\fR, `, escapeRoff(item.ShortCmd))
+		}
+		fmt.Fprintf(w, `\fB\-\-%s\fR`, escapeRoff(item.LongCmd))
+		if item.Type != BoolArg {
+			fmt.Fprintf(w, ` \fI%s\fR`, escapeRoff(item.getValueName()))
+		}
+		fmt.Fprintf(w, "\n%s\n", escapeRoff(item.getHelp()))
+	}
+}
+
+// writeManHeader write the title and the name of a man page.
+func writeManHeader(w io.Writer, name, description string) {
+	fmt.Fprintf(w, ".TH %s 1 \"\" \"%s\" \"User Commands\"\n",
+		escapeRoff(strings.ToUpper(name)), AppName)
+	fmt.Fprintf(w, ".SH NAME\n%s \\- %s\n", escapeRoff(name),
+		escapeRoff(description))
+}
+
+// writeManFooter write environment variables and files of a man page.
+func writeManFooter(w io.Writer) {
+	fmt.Fprintf(w, ".SH ENVIRONMENT\n%s\n", escapeRoff("Values of "+
+			"options can be set by environment variables like "+
+			EnvPrefix+ "<SECTION>_<KEY>, such as "+ EnvPrefix+
+			"DOWNLOAD_PATH, they overwrite values in config.ini and are "+
+			"overwritten by arguments."))
+	fmt.Fprintf(w, ".SH FILES\n.TP\n.B config.ini\n%s\n.TP\n.B %s\n%s\n",
+		escapeRoff("The config in the working folder, it is made with "+
+				"default values if it does not exist."),
+		escapeRoff(CookieFileName), escapeRoff("The cookie of the session."))
+}
+
+// writeMan write the man page of this app with all commands.
+func (p *Pixiv) writeMan(w io.Writer) {
+	writeManHeader(w, AppName, AppDescription)
+	fmt.Fprintf(w, ".SH SYNOPSIS\n.B %s\n%s\n", AppName,
+		`[\fIglobal options\fR] \fIcommand\fR [\fIoptions\fR]`)
+	fmt.Fprintf(w, ".SH GLOBAL OPTIONS\n")
+	writeManArgs(w, getArgHelps(p.GlobalArgData,
+		reflect.ValueOf(p.Config.Client).Elem()))
+	fmt.Fprintf(w, ".SH COMMANDS\n")
+	for _, cmdData := range p.getSortedCmdData() {
+		fmt.Fprintf(w, ".SS %s\n%s\n.PP\n%s\n", escapeRoff(cmdData.Cmd),
+			escapeRoff(getUsage(&cmdData)), escapeRoff(cmdData.Help))
+		writeManArgs(w, getArgHelps(cmdData.ArgData, p.getCmdVal(cmdData)))
+	}
+	writeManFooter(w)
+}
+
+// writeCmdMan write the man page of the command.
+func (p *Pixiv) writeCmdMan(w io.Writer, cmdData CmdData) {
+	writeManHeader(w, AppName+"-"+cmdData.Cmd, cmdData.Help)
+	fmt.Fprintf(w, ".SH SYNOPSIS\n%s\n", escapeRoff(getUsage(&cmdData)))
+	fmt.Fprintf(w, ".SH OPTIONS\n")
+	writeManArgs(w, getArgHelps(cmdData.ArgData, p.getCmdVal(cmdData)))
+	writeManFooter(w)
+	fmt.Fprintf(w, ".SH SEE ALSO\n.BR %s (1)\n", AppName)
+}
+
+// doHelp write help to w if it is asked by "help [--man] [<command>]",
+// "--help" before the command or "<command> --help", isHelp is false if
+// help is not asked.
+func (p *Pixiv) doHelp(w io.Writer) (isHelp bool, err error) {
+	var (
+		cmdIndex = p.getCmdIndex()
+		cmdData  CmdData
+		isExist  bool
+	)
+	for _, arg := range p.args[:cmdIndex] {
+		if isHelpArg(arg) {
+			p.writeUsage(w)
+			return true, nil
+		}
+	}
+	if cmdIndex == len(p.args) {
+		// Usage is written to stderr because help is not asked.
+		p.writeUsage(os.Stderr)
+		fmt.Fprintln(os.Stderr)
+		return true, throw(p, "command is required")
+	}
+	
+	if p.args[cmdIndex] == HelpCmd {
+		var (
+			isMan  bool
+			cmdStr string
+		)
+		for _, arg := range p.args[cmdIndex+1:] {
+			if arg == "--man" {
+				isMan = true
+			} else if cmdStr == "" && !strings.HasPrefix(arg, "-") {
+				cmdStr = arg
+			} else {
+				return true, throw(p, "argument \""+ arg+
+						"\" of command \"help\" is unexpected")
+			}
+		}
+		if cmdStr == "" {
+			if isMan {
+				p.writeMan(w)
+			} else {
+				p.writeUsage(w)
+			}
+			return true, nil
+		}
+		if cmdData, isExist = p.CmdData[cmdStr]; !isExist {
+			return true, throw(p, "command \""+ cmdStr+ "\" not found")
+		}
+		if isMan {
+			p.writeCmdMan(w, cmdData)
+		} else {
+			p.writeCmdHelp(w, cmdData)
+		}
+		return true, nil
+	}
+	
+	if cmdData, isExist = p.CmdData[p.args[cmdIndex]]; !isExist {
+		return false, nil
+	}
+	for _, arg := range p.args[cmdIndex+1:] {
+		if arg == "--" {
+			break
+		}
+		if isHelpArg(arg) {
+			p.writeCmdHelp(w, cmdData)
+			return true, nil
+		}
+	}
+	return false, nil
+}
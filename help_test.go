@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	
+	"github.com/abc1236762/pixiv_tool/internal/golden"
+)
+
+func TestPixivDoHelp(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		args string
+	}{
+		{"Usage", "--record cassette --help"},
+		{"Cmd", "download 100 --help"},
+		{"HelpCmd", "help login"},
+		{"Man", "help --man"},
+		{"CmdMan", "help --man logout"},
+		{"NotFound", "help upload"},
+		{"NotHelp", "download -- --help"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				p   = &Pixiv{args: strings.Fields(test.args)}
+				buf bytes.Buffer
+				g   golden.File
+			)
+			p.initCmdData()
+			p.initConfig()
+			var isHelp, err = p.doHelp(&buf)
+			g.Result(err)
+			if isHelp {
+				g.Section("help", buf.String())
+			}
+			g.Check(t)
+		})
+	}
+}
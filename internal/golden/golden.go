@@ -0,0 +1,71 @@
+// Package golden compare results of tests with golden files in
+// "testdata/golden" of the package that is tested, golden files are
+// rewritten by results with "go test -update".
+package golden
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update is set by "go test -update" to rewrite golden files by results.
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// Dir is the folder of golden files.
+const Dir = "testdata/golden"
+
+// A File is the result of a test that is compared with its golden file.
+type File struct {
+	buf bytes.Buffer
+}
+
+// Section add a section named name with lines to the result.
+func (f *File) Section(name string, lines ...string) {
+	fmt.Fprintf(&f.buf, "[%s]\n", name)
+	for _, line := range lines {
+		fmt.Fprintln(&f.buf, line)
+	}
+	fmt.Fprintln(&f.buf)
+}
+
+// Result add the error of a test to the result.
+func (f *File) Result(err error) {
+	if err != nil {
+		f.Section("error", err.Error())
+	} else {
+		f.Section("error", "<nil>")
+	}
+}
+
+// Check compare the result with the golden file named by the test, or
+// write the golden file if update is set.
+func (f *File) Check(t *testing.T) {
+	var (
+		name     = filepath.Join(Dir, strings.ReplaceAll(
+			t.Name(), "/", "_")+ ".golden")
+		expected []byte
+		err      error
+	)
+	if *update {
+		if err = os.MkdirAll(Dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(name, f.buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if expected, err = ioutil.ReadFile(name); err != nil {
+		t.Fatalf("%v, run with -update to create it", err)
+	}
+	if !bytes.Equal(expected, f.buf.Bytes()) {
+		t.Errorf("result is not the same as %s:\n--- expected\n%s"+
+				"--- actual\n%s", name, expected, f.buf.Bytes())
+	}
+}
@@ -1,48 +1,41 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"log"
 	"os"
+	"os/signal"
 	"reflect"
-	"strconv"
 	"strings"
+	"syscall"
 	
+	"github.com/abc1236762/pixiv_tool/pixiv"
 	"gopkg.in/ini.v1"
 )
 
+// A Doer run a command, it should stop when ctx is done.
 type Doer interface {
-	Do() error
+	Do(ctx context.Context) error
 }
 
 // A Pixiv save config data and command data about this app.
 // Some functions in Pixiv use panic to throw error because
 // these should be fixed before release.
 type Pixiv struct {
-	Config  *Config
-	CmdData map[reflect.Type]CmdData
-}
-
-// A CmdData save data of commands about this app.
-type CmdData struct {
-	Cmd     string
-	Help    string
-	ArgData map[string]ArgData
-}
-
-// A ArgData save data of arguments of each command about this app.
-type ArgData struct {
-	LongCmd    string
-	ShortCmd   string
-	Type       reflect.Kind
-	Help       string
-	IsRequired bool
+	Config        *Config
+	CmdData       map[string]CmdData
+	GlobalArgData map[string]ArgData
+	
+	args []string
 }
 
-// A Config have each function include values from config.ini and default.
+// A Config have the Client and doers of commands by names, their values
+// are from defaults, config.ini, environment variables and arguments in
+// order.
 type Config struct {
-	*Client `cmd:"-"`
-	*Login
-	*Logout
-	*Download
+	Client *pixiv.Client
+	Cmds   map[string]Doer
 }
 
 // Do initialize contents of Pixiv and run selected function.
@@ -54,305 +47,309 @@ func (p *Pixiv) Do() (err error) {
 		panic("pixiv: struct \"Pixiv\" should be blank inside when Run")
 	}
 	p.initCmdData()
-	p.checkCmdData()
+	if err = p.checkCmdData(); err != nil {
+		return err
+	}
+	p.args = os.Args[1:]
 	
-	// Get config value to command data.
+	// Help is written before loading config, so default values are shown
+	// and config.ini is not made by it.
 	p.initConfig()
+	var isDone bool
+	if isDone, err = p.doHelp(os.Stdout); isDone || err != nil {
+		return err
+	}
+	if isDone, err = p.doCompletion(os.Stdout); isDone || err != nil {
+		return err
+	}
+	
+	// Get config value to command data, global arguments before the command
+	// overwrite values of Client in config.
 	if err = p.loadConfig(); err != nil {
 		return err
 	}
+	if err = p.parseGlobalArgs(); err != nil {
+		return err
+	}
+	if err = p.Config.Client.Init(); err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := p.Config.Client.Close(); err == nil {
+			err = closeErr
+		}
+	}()
 	
-	// Parse command and arguments and run selected function.
+	// Parse command and arguments and run selected function, it is stopped
+	// by SIGINT, SIGTERM or Client.TotalTimeout.
 	if doer, err = p.makeDoer(); err != nil {
 		return err
 	}
-	return doer.Do()
+	var ctx, stop = notifySignals()
+	ctx, cancel := p.Config.Client.WithTotalTimeout(ctx)
+	defer cancel()
+	err = doer.Do(ctx)
+	// The context must be checked before stop, which cancel it.
+	var isStopped = err != nil && ctx.Err() != nil
+	var received = stop()
+	if !isStopped {
+		return err
+	}
+	
+	// Save the cookie jar when the command is stopped, so the session is
+	// kept for next time.
+	if saveErr := p.Config.Client.SaveCookie(); saveErr != nil {
+		log.Printf("pixiv: failed to save the cookie: %v", saveErr)
+	}
+	if received != nil {
+		return throw(p, "stopped by signal \""+ received.String()+ "\"")
+	}
+	return throw(p, "stopped by timeout \""+
+			p.Config.Client.TotalTimeout+ "\"")
+}
+
+// notifySignals make a context that is canceled when SIGINT or SIGTERM is
+// received. Running downloads are finished or kept to be resumed after the
+// first signal, and this app quit at once by the second signal. stop
+// should be called after running, it wait until signals are not watched
+// and return the first received signal, or nil if no signal is received.
+func notifySignals() (ctx context.Context, stop func() os.Signal) {
+	var (
+		signals  = make(chan os.Signal, 2)
+		done     = make(chan struct{})
+		finished = make(chan struct{})
+		received os.Signal
+		cancel   context.CancelFunc
+	)
+	ctx, cancel = context.WithCancel(context.Background())
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer close(finished)
+		select {
+		case received = <-signals:
+			log.Printf("pixiv: stopping by signal \"%v\", "+
+					"send it again to quit at once", received)
+			cancel()
+		case <-done:
+			return
+		}
+		select {
+		case <-signals:
+			os.Exit(1)
+		case <-done:
+		}
+	}()
+	return ctx, func() os.Signal {
+		signal.Stop(signals)
+		close(done)
+		<-finished
+		cancel()
+		return received
+	}
 }
 
-// initCmdData initialize contents of Pixiv.CmdData.
+// initCmdData initialize Pixiv.GlobalArgData, and copy commands registered
+// by RegisterCmd to Pixiv.CmdData.
 func (p *Pixiv) initCmdData() {
-	p.CmdData = map[reflect.Type]CmdData{
-		reflect.TypeOf(Login{}): {
-			Cmd:  "login",
-			Help: "Login Pixiv",
-			ArgData: map[string]ArgData{
-				"Username": {
-					LongCmd:    "username",
-					ShortCmd:   "u",
-					Type:       reflect.String,
-					Help:       "the username of your Pixiv account",
-					IsRequired: true,
-				},
-				"Password": {
-					LongCmd:    "password",
-					ShortCmd:   "p",
-					Type:       reflect.String,
-					Help:       "the password of your Pixiv account",
-					IsRequired: true,
-				},
-			},
+	p.GlobalArgData = map[string]ArgData{
+		"MaxRetries": {
+			LongCmd:    "max-retries",
+			Type:       IntArg,
+			Help:       "the max times of retrying a failed request",
+			IsRequired: false,
+		},
+		"RetryWait": {
+			LongCmd:    "retry-wait",
+			Type:       DurationArg,
+			Help:       "the time of waiting before the first retry, it is doubled after each retry",
+			IsRequired: false,
+		},
+		"Record": {
+			LongCmd:    "record",
+			Type:       StringArg,
+			Value:      FolderValue,
+			Help:       "record responses to the folder",
+			IsRequired: false,
+		},
+		"Replay": {
+			LongCmd:    "replay",
+			Type:       StringArg,
+			Value:      FolderValue,
+			Help:       "replay responses recorded in the folder without network",
+			IsRequired: false,
+		},
+		"RequestTimeout": {
+			LongCmd:    "request-timeout",
+			Type:       DurationArg,
+			Help:       "the max time of waiting for response headers of a request, reading the body is not limited",
+			IsRequired: false,
 		},
-		reflect.TypeOf(Logout{}): {
-			Cmd:  "logout",
-			Help: "Logout Pixiv",
-			ArgData: map[string]ArgData{
-				"WillDeleteCookie": {
-					LongCmd:    "delete-cookie",
-					ShortCmd:   "d",
-					Type:       reflect.Bool,
-					Help:       "delete the cookie",
-					IsRequired: false,
-				},
-			},
+		"TotalTimeout": {
+			LongCmd:    "timeout",
+			Type:       DurationArg,
+			Help:       "the max time of running the command, it is stopped like receiving Ctrl-C after that",
+			IsRequired: false,
 		},
-		reflect.TypeOf(Download{}): {
-			Cmd:  "download",
-			Help: "Download a work from the ID or works from a list in Pixiv",
-			ArgData: map[string]ArgData{
-				"IDOrList": {
-					LongCmd:    "id-or-list",
-					ShortCmd:   "i",
-					Type:       reflect.String,
-					Help:       "the work ID that want to download in Pixiv or filename of the list that generate by other commands",
-					IsRequired: true,
-				},
-				"Path": {
-					LongCmd:    "path",
-					ShortCmd:   "p",
-					Type:       reflect.String,
-					Help:       "where the download file(s) will be save, must be a folder",
-					IsRequired: false,
-				},
-			},
+		"HAR": {
+			LongCmd:    "har",
+			Type:       StringArg,
+			Value:      FileValue,
+			Help:       "save requests and responses to the file in HAR format for debugging, cookies and passwords are redacted",
+			IsRequired: false,
 		},
 	}
+	p.CmdData = make(map[string]CmdData, len(cmdRegistry))
+	for cmd, cmdData := range cmdRegistry {
+		p.CmdData[cmd] = cmdData
+	}
 }
 
-// checkCmdData check contents of Pixiv.CmdData and check each function of
-// this app matches the requirements of Pixiv.CmdData or not.
-func (p *Pixiv) checkCmdData() {
-	var panicMsg []string
-	
-	for i, types := 0, reflect.TypeOf(p.Config).Elem();
-			i < types.NumField(); i++ {
-		var (
-			cmdField     = types.Field(i)
-			cmdFieldType = cmdField.Type.Elem()
-			cmdData      CmdData
-			haveCmdData  bool
-		)
-		
-		// When field is "Client",
-		// the tag of "cmd" must be "-" because it is not a command.
-		if cmdField.Name == "Client" && cmdField.Tag.Get("cmd") != "-" {
-			panicMsg = append(panicMsg, "tag \"cmd\" of \""+
-					cmdField.Name+ "\" should be \"-\"")
-		}
-		if cmdField.Name != "Client" && cmdField.Tag.Get("cmd") == "-" {
-			panicMsg = append(panicMsg, "tag \"cmd\" of \""+
-					cmdField.Name+ "\" should not be \"-\"")
-		}
-		if cmdField.Tag.Get("cmd") == "-" {
-			continue
-		}
-		
-		// When field is not "Client",
-		// it should have a data in "CmdData" because it is a command.
-		if cmdData, haveCmdData = p.CmdData[cmdFieldType]; !haveCmdData {
-			panicMsg = append(panicMsg, "\""+
-					cmdField.Name+ "\" does not have CmdData")
-			continue
-		}
-		
-		for j := 0; j < cmdFieldType.NumField(); j++ {
-			var (
-				argField    = cmdFieldType.Field(j)
-				argData     ArgData
-				haveArgData bool
-			)
-			
-			// When field is "Client", the tag of "ini"
-			// must be "-" because it is not a option or argument.
-			if argField.Name == "Client" && argField.Tag.Get("ini") != "-" {
-				panicMsg = append(panicMsg, "tag \"ini\" of \""+
-						cmdField.Name+ "."+ argField.Name+ "\" should be \"-\"")
-			} else if argField.Name != "Client" {
-				
-				// When field not exist in "ArgData", the tag of "ini"
-				// must be ",omitempty" because it is a option.
-				if argData, haveArgData =
-						cmdData.ArgData[argField.Name]; !haveArgData {
-					if argField.Tag.Get("ini") == ",omitempty" {
-						continue
-					}
-					panicMsg = append(panicMsg, "\"" + cmdField.Name+
-							"."+ argField.Name+ "\" does not have ArgData")
-				}
-				
-				// When argument is required, the tag of "ini"
-				// must be "-" or ",omitempty", "-" means the argument
-				// must be gotten from input, ",omitempty" means the
-				// argument can be gotten from input and then ini file.
-				// When argument is not required, it should have
-				// a default value, so the tag of "ini" must be empty.
-				if argData.IsRequired && (argField.Tag.Get("ini") !=
-						",omitempty" && argField.Tag.Get("ini") != "-") {
-					panicMsg = append(panicMsg, "tag \"ini\" of \""+
-							cmdField.Name+ "."+ argField.Name+
-							"\" should be \",omitempty\" or \"-\"")
-				} else if !argData.IsRequired && (argField.Tag.Get("ini") ==
-						",omitempty" || argField.Tag.Get("ini") == "-") {
-					panicMsg = append(panicMsg, "tag \"ini\" of \""+
-							cmdField.Name+ "."+ argField.Name+
-							"\" should not be \",omitempty\" or \"-\"")
-				}
-				
-			}
-		}
+// initConfig initialize contents of Pixiv.Config with default values, the
+// Client save the cookie to CookieFileName and log to stderr.
+func (p *Pixiv) initConfig() {
+	p.Config = &Config{
+		Client: pixiv.NewClient(),
+		Cmds:   make(map[string]Doer, len(p.CmdData)),
 	}
-	
-	if len(panicMsg) > 0 {
-		panic("pixiv: error(s) occurred when checkCmdData:\n\t" +
-				strings.Join(panicMsg, ",\n\t"))
+	p.Config.Client.CookieFile = CookieFileName
+	p.Config.Client.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	for cmd, cmdData := range p.CmdData {
+		p.Config.Cmds[cmd] = cmdData.New()
 	}
 }
 
-// initConfig initialize contents of Pixiv.Config with default values.
-func (p *Pixiv) initConfig() {
-	p.Config = &Config{
-		Client: &Client{
-			UserAgent: getUserAgent(),
-		},
-		Login: &Login{
-		},
-		Logout: &Logout{
-			WillDeleteCookie: false,
-		},
-		Download: &Download{
-			Path: "./",
-			Naming: Naming{
-				SingleFile:   "<artist.name>/(<work.id>) <work.name>",
-				MultipleFile: "<work.page>",
-				Folder:       "<artist.name>/(<work.id>) <work.name>",
-			},
-			Metadata: "",
-		},
+// reflectConfig set values of Pixiv.Config to sections of file.
+func (p *Pixiv) reflectConfig(file *ini.File) (err error) {
+	if err = file.Section(ClientSection).ReflectFrom(
+		p.Config.Client); err != nil {
+		return err
 	}
+	for _, cmdData := range p.getSortedCmdData() {
+		if err = file.Section(cmdData.Section).ReflectFrom(
+			p.Config.Cmds[cmdData.Cmd]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // loadConfig load config.ini and set values to Pixiv.Config.
 func (p *Pixiv) loadConfig() (err error) {
-	var config *ini.File
+	var (
+		config, defaults = (*ini.File)(nil), ini.Empty()
+		defaultsBuf      bytes.Buffer
+	)
 	if _, err = os.Stat("config.ini"); os.IsNotExist(err) {
 		if err = p.saveConfig(); err != nil {
 			return err
 		}
 	}
-	if config, err = ini.Load("config.ini"); err != nil {
+	
+	// Load default values before config.ini, so values that are not in
+	// config.ini will not be cleared when mapping to Pixiv.Config.
+	if err = p.reflectConfig(defaults); err != nil {
+		return err
+	}
+	if _, err = defaults.WriteTo(&defaultsBuf); err != nil {
+		return err
+	}
+	if config, err = ini.Load(defaultsBuf.Bytes(), "config.ini"); err != nil {
+		return err
+	}
+	if err = config.Section(ClientSection).MapTo(p.Config.Client); err != nil {
+		return err
+	}
+	for _, cmdData := range p.getSortedCmdData() {
+		if err = config.Section(cmdData.Section).MapTo(
+			p.Config.Cmds[cmdData.Cmd]); err != nil {
+			return err
+		}
+	}
+	if err = p.loadEnv(); err != nil {
 		return err
 	}
-	return config.MapTo(p.Config)
+	
+	// Check values of config, invalid values should be found here
+	// instead of when running.
+	for _, cmdData := range p.getSortedCmdData() {
+		if checker, ok := p.Config.Cmds[cmdData.Cmd].(configChecker); ok {
+			if err = checker.checkConfig(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadEnv set values of environment variables like "PIXIV_DOWNLOAD_PATH"
+// to Pixiv.Config, they overwrite values from config.ini.
+func (p *Pixiv) loadEnv() error {
+	var errMsgs = loadEnv(ClientSection,
+		reflect.ValueOf(p.Config.Client).Elem())
+	for _, cmdData := range p.getSortedCmdData() {
+		errMsgs = append(errMsgs, loadEnv(cmdData.Section,
+			reflect.ValueOf(p.Config.Cmds[cmdData.Cmd]).Elem())...)
+	}
+	if len(errMsgs) > 0 {
+		return throw(p, "invalid environment variable(s):\n\t"+
+				strings.Join(errMsgs, ",\n\t"))
+	}
+	return nil
 }
 
 // saveConfig get values of Pixiv.Config and save to config.ini.
 func (p *Pixiv) saveConfig() (err error) {
 	var config = ini.Empty()
-	if err = config.ReflectFrom(p.Config); err != nil {
+	if err = p.reflectConfig(config); err != nil {
 		return err
 	}
 	return config.SaveTo("config.ini")
 }
 
+// parseGlobalArgs parse arguments before the command like
+// "--record <folder>" and set to Client, they are removed from
+// Pixiv.args.
+func (p *Pixiv) parseGlobalArgs() (err error) {
+	var (
+		parser = &argParser{
+			Name:    "pixiv",
+			ArgData: p.GlobalArgData,
+			Value:   reflect.ValueOf(p.Config.Client).Elem(),
+		}
+		n int
+	)
+	n, err = parser.parse(p.args, true)
+	p.args = p.args[n:]
+	return err
+}
+
 // makeDoer make a doer that correspond to command and include arguments.
 func (p *Pixiv) makeDoer() (doer Doer, err error) {
-	if len(os.Args) <= 1 {
+	if len(p.args) == 0 {
 		return nil, throw(p, "command is required")
 	}
-	if doer = p.getCmdDoer(os.Args[1]); doer == nil {
-		return nil, throw(p, "command \""+os.Args[1]+"\" not found")
+	var cmdData, isExist = p.CmdData[p.args[0]]
+	if !isExist {
+		return nil, throw(p, "command \""+p.args[0]+"\" not found")
 	}
-	if err = p.parseArgs(doer); err != nil {
+	doer = p.Config.Cmds[cmdData.Cmd]
+	reflect.ValueOf(doer).Elem().FieldByName("Client").
+		Set(reflect.ValueOf(p.Config.Client))
+	if err = p.parseArgs(cmdData, doer); err != nil {
 		return nil, err
 	}
 	return doer, nil
 }
 
-// getCmdDoer get the corresponding doer of command.
-func (p *Pixiv) getCmdDoer(cmdStr string) (doer Doer) {
-	for cmd, data := range p.CmdData {
-		if cmdStr == data.Cmd {
-			doer = reflect.ValueOf(p.Config).Elem().
-				FieldByName(cmd.Name()).Interface().(Doer)
-			reflect.ValueOf(doer).Elem().FieldByName("Client").
-				Set(reflect.ValueOf(p.Config.Client))
-			return doer
-		}
+// parseArgs parse arguments of the command and set to doer, values that
+// are not given keep values from config.ini, environment variables or
+// defaults.
+func (p *Pixiv) parseArgs(cmdData CmdData, doer Doer) (err error) {
+	var parser = &argParser{
+		Name:    cmdData.Cmd,
+		ArgData: cmdData.ArgData,
+		Value:   reflect.ValueOf(doer).Elem(),
 	}
-	return nil
-}
-
-// parseArgs parse arguments of the command and set to doer.
-func (p *Pixiv) parseArgs(doer Doer) (err error) {
-	// TODO parseArgs
-	var (
-		addedArgs, errMsgs []string
-		isValue            = false
-		args               = os.Args[2:]
-		cmdStr             = reflect.TypeOf(doer).Elem().Name()
-		argData            = p.CmdData[reflect.TypeOf(doer).Elem()].ArgData
-		doerVal            = reflect.ValueOf(doer).Elem()
-	)
-	
-	//
-Loop:
-	for i, argv := range args {
-		var (
-			isMatched bool
-			argName   string
-		)
-		if isValue {
-			isValue = false
-			continue
-		}
-		for name, data := range argData {
-			if argv == "-"+data.ShortCmd || argv == "--"+data.LongCmd {
-				addedArgs = append(addedArgs, name)
-				argName = name
-				isMatched = true
-			}
-		}
-		if !isMatched {
-			errMsgs = append(errMsgs, "argument \"" + argv+
-					"\" not found in command \""+ cmdStr+ "\"")
-			continue
-		}
-		if argData[argName].Type != reflect.Bool {
-			isValue = true
-		}
-		for _, arg := range addedArgs[:len(addedArgs)-1] {
-			if arg == argName {
-				errMsgs = append(errMsgs, "argument \"" + "-"+
-						argData[argName].ShortCmd+ "\" or \""+ "--"+
-						argData[argName].LongCmd+ "\" is duplicated")
-				continue Loop
-			}
-		}
-		switch argData[argName].Type {
-		case reflect.Bool:
-			doerVal.FieldByName(argName).SetBool(true)
-		case reflect.Int:
-			var argvInt int64
-			if argvInt, err = strconv.ParseInt(args[i+1], 10, 64); err != nil {
-				errMsgs = append(errMsgs, "value of argument \"" + argv+
-						"\" require a number, \""+ args[i+1]+ "\" may not")
-			} else {
-				doerVal.FieldByName(argName).SetInt(argvInt)
-			}
-		
-		case reflect.String:
-			doerVal.FieldByName(argName).SetString(args[i+1])
-		}
-	}
-	
-	return nil
+	_, err = parser.parse(p.args[1:], false)
+	return err
 }
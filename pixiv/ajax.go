@@ -0,0 +1,180 @@
+package pixiv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+)
+
+// An ajaxResponse is the response of AJAX API of Pixiv, Body is the data
+// if Error is false, otherwise Message is the reason.
+type ajaxResponse struct {
+	Error   bool            `json:"error"`
+	Message string          `json:"message"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// An ajaxWork is the body of PixivWorkDataURL.
+type ajaxWork struct {
+	ID          string    `json:"illustId"`
+	Title       string    `json:"illustTitle"`
+	Comment     string    `json:"illustComment"`
+	Type        int       `json:"illustType"`
+	CreateDate  time.Time `json:"createDate"`
+	PageCount   uint64    `json:"pageCount"`
+	UserID      string    `json:"userId"`
+	UserName    string    `json:"userName"`
+	UserAccount string    `json:"userAccount"`
+	Tags        struct {
+		Tags []struct {
+			Tag string `json:"tag"`
+		} `json:"tags"`
+	} `json:"tags"`
+	SeriesNavData *struct {
+		Title string `json:"title"`
+	} `json:"seriesNavData"`
+}
+
+// An ajaxPage is an item of the body of PixivWorkPagesURL.
+type ajaxPage struct {
+	URLs struct {
+		Original string `json:"original"`
+	} `json:"urls"`
+	Width  uint64 `json:"width"`
+	Height uint64 `json:"height"`
+}
+
+// An ajaxUser is the body of PixivUserDataURL.
+type ajaxUser struct {
+	ID   string `json:"userId"`
+	Name string `json:"name"`
+}
+
+// An ajaxSource is a MetadataSource that get data from AJAX API of Pixiv.
+type ajaxSource struct {
+	Client *Client
+}
+
+// getAJAXBody get the body of AJAX API from URL to body, what is the name
+// of data for errors.
+func getAJAXBody(ctx context.Context, client *Client, url, what string,
+		body interface{}) (err error) {
+	var (
+		resp      *http.Response
+		bodyBytes []byte
+		response  ajaxResponse
+	)
+	if resp, err = client.GetContext(ctx, url); err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if bodyBytes, err = ioutil.ReadAll(resp.Body); err != nil {
+		return err
+	}
+	
+	// AJAX API return an error message in JSON even if status is not OK.
+	if err = json.Unmarshal(bodyBytes, &response); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return throw(AJAXSource,
+				"request status is not OK when getting "+ what)
+		}
+		return &ParseError{Prefix: AJAXSource, Field: url, Pattern: "JSON",
+			Snippet: getSnippet(string(bodyBytes), nil), Err: err}
+	}
+	if response.Error || resp.StatusCode != http.StatusOK {
+		return throw(AJAXSource,
+			"failed to get "+ what+ ": "+ response.Message)
+	}
+	if err = json.Unmarshal(response.Body, body); err != nil {
+		return &ParseError{Prefix: AJAXSource, Field: url, Pattern: "JSON",
+			Snippet: getSnippet(string(response.Body), nil), Err: err}
+	}
+	return nil
+}
+
+// FetchWork get data of work and artist from AJAX API. All pages with URL
+// and size are got by one request.
+func (s *ajaxSource) FetchWork(ctx context.Context, id string) (
+		artistData *ArtistData, workData *WorkData, err error) {
+	var (
+		work  ajaxWork
+		pages []ajaxPage
+	)
+	
+	artistData, workData = new(ArtistData), &WorkData{ID: id}
+	if err = getAJAXBody(ctx, s.Client, fmt.Sprintf(PixivWorkDataURL, id),
+		"work \""+ id+ "\"", &work); err != nil {
+		return nil, nil, err
+	}
+	if err = getAJAXBody(ctx, s.Client, fmt.Sprintf(PixivWorkPagesURL, id),
+		"pages of work \""+ id+ "\"", &pages); err != nil {
+		return nil, nil, err
+	}
+	
+	artistData.ID = work.UserID
+	artistData.Username = work.UserAccount
+	artistData.Nickname = work.UserName
+	if artistData.Nickname == "" && artistData.ID != "" {
+		var user ajaxUser
+		if err = getAJAXBody(ctx, s.Client,
+			fmt.Sprintf(PixivUserDataURL, artistData.ID),
+			"user \""+ artistData.ID+ "\"", &user); err != nil {
+			return nil, nil, err
+		}
+		artistData.Nickname = user.Name
+	}
+	
+	workData.Name = work.Title
+	workData.Time = work.CreateDate
+	workData.Caption = work.Comment
+	workData.PageCount = work.PageCount
+	if work.SeriesNavData != nil {
+		workData.Series = work.SeriesNavData.Title
+	}
+	workData.Tags = make([]string, len(work.Tags.Tags))
+	for i, tag := range work.Tags.Tags {
+		workData.Tags[i] = tag.Tag
+	}
+	
+	// Type of work is 0 for illust, 1 for manga and 2 for ugoira.
+	switch work.Type {
+	case 1:
+		workData.Type = Manga
+	case 2:
+		workData.Type = Ugoira
+	default:
+		workData.Type = Illust
+	}
+	
+	// Pages are required, other fields are empty if they are not given.
+	if len(pages) == 0 {
+		return nil, nil, &ParseError{Prefix: AJAXSource, Field: "pages",
+			WorkID: id, Snippet: "[]"}
+	}
+	workData.Pages = make([]PageData, len(pages))
+	for i, page := range pages {
+		if page.URLs.Original == "" {
+			var pageBytes, _ = json.Marshal(page)
+			return nil, nil, &ParseError{Prefix: AJAXSource,
+				Field:   "pages." + strconv.Itoa(i) + ".urls.original",
+				WorkID:  id,
+				Snippet: getSnippet(string(pageBytes), nil)}
+		}
+		workData.Pages[i] = PageData{
+			Page:     uint64(i),
+			Width:    page.Width,
+			Height:   page.Height,
+			Filename: path.Base(page.URLs.Original),
+			ImageURL: page.URLs.Original,
+		}
+	}
+	if workData.PageCount == 0 {
+		workData.PageCount = uint64(len(pages))
+	}
+	return artistData, workData, nil
+}
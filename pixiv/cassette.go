@@ -0,0 +1,157 @@
+package pixiv
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// cassetteNameRegexp match characters that are not used in names of
+// cassette files.
+var cassetteNameRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// A cassetteTransport is a http.RoundTripper that record responses of
+// requests to files in Dir, or replay responses from them without network
+// if IsReplay is true. Each response is saved in a file like
+// "GET_www.pixiv.net_ajax_illust_100-1a2b3c4d-0.http" as raw HTTP, the
+// name is made from the request and the number of times the same request
+// is sent, so a request sent several times like the home page can be
+// replayed with a different response each time. Values of headers with
+// cookies and credentials are replaced by HARRedacted in files, so they
+// can be shared without the session.
+type cassetteTransport struct {
+	Dir       string
+	IsReplay  bool
+	Transport http.RoundTripper
+	
+	counts map[string]int
+	mutex  sync.Mutex
+}
+
+// A cassetteMissError is the error that no response of a request is
+// recorded when replaying, the request is not sent again by Client.Do
+// because it will never be found.
+type cassetteMissError struct {
+	*AppError
+}
+
+// getCassetteKey get the key of request from its method, URL and body,
+// the body is read and replaced so it can be sent again.
+func getCassetteKey(req *http.Request) (_ string, err error) {
+	var (
+		hash = sha1.New()
+		body []byte
+		name = req.Method + "_" + req.URL.Host + req.URL.Path
+	)
+	io.WriteString(hash, req.Method+" "+req.URL.String())
+	if req.Body != nil && req.Body != http.NoBody {
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return "", err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		hash.Write(body)
+	}
+	name = cassetteNameRegexp.ReplaceAllString(name, "_")
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	return name + "-" + hex.EncodeToString(hash.Sum(nil)[:4]), nil
+}
+
+// getFilename get the filename of the response of request in Dir.
+func (ct *cassetteTransport) getFilename(req *http.Request) (_ string,
+		err error) {
+	var key string
+	if key, err = getCassetteKey(req); err != nil {
+		return "", err
+	}
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+	if ct.counts == nil {
+		ct.counts = make(map[string]int)
+	}
+	var count = ct.counts[key]
+	ct.counts[key]++
+	
+	// When replaying, the last response is used again if the request is
+	// sent more times than recorded.
+	var filename = filepath.Join(ct.Dir,
+		key+"-"+strconv.Itoa(count)+".http")
+	for ; ct.IsReplay && count > 0; count-- {
+		if isExist, _ := isFileExist(filename); isExist {
+			break
+		}
+		filename = filepath.Join(ct.Dir,
+			key+"-"+strconv.Itoa(count-1)+".http")
+	}
+	return filename, nil
+}
+
+// getRedactedHeader get a copy of header that values of headers that
+// isRedactedHeader return true are replaced by HARRedacted.
+func getRedactedHeader(header http.Header) http.Header {
+	var redacted = make(http.Header, len(header))
+	for name, values := range header {
+		if isRedactedHeader(name) {
+			values = []string{HARRedacted}
+		}
+		redacted[name] = values
+	}
+	return redacted
+}
+
+// RoundTrip is needed when implement a http.RoundTripper interface.
+func (ct *cassetteTransport) RoundTrip(req *http.Request) (
+		resp *http.Response, err error) {
+	var (
+		filename string
+		dump     []byte
+	)
+	if filename, err = ct.getFilename(req); err != nil {
+		return nil, err
+	}
+	
+	if ct.IsReplay {
+		if dump, err = ioutil.ReadFile(filename); os.IsNotExist(err) {
+			return nil, &cassetteMissError{&AppError{Prefix: "cassette",
+				Msg: "no recorded response of " + req.Method + " \"" +
+						req.URL.String() + "\" in \"" + ct.Dir + "\""}}
+		} else if err != nil {
+			return nil, err
+		}
+		return http.ReadResponse(bufio.NewReader(bytes.NewReader(dump)), req)
+	}
+	
+	if resp, err = ct.Transport.RoundTrip(req); err != nil {
+		return nil, err
+	}
+	// The body of response is read and replaced by DumpResponse, and the
+	// headers are redacted in a copy so the session is still kept by the
+	// cookie jar.
+	var saved = *resp
+	saved.Header = getRedactedHeader(resp.Header)
+	dump, err = httputil.DumpResponse(&saved, true)
+	resp.Body = saved.Body
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if err = os.MkdirAll(ct.Dir, 0755); err != nil {
+		return nil, err
+	}
+	if err = ioutil.WriteFile(filename, dump, 0600); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
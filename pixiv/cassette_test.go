@@ -0,0 +1,105 @@
+package pixiv
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCassetteTransport check works downloaded by recorded responses are
+// the same as downloaded from the fake Pixiv.
+func TestCassetteTransport(t *testing.T) {
+	var (
+		fp         = newFakePixiv(t)
+		cassette   = t.TempDir()
+		recordTree []string
+	)
+	for _, isReplay := range []bool{false, true} {
+		var client = fp.client(true)
+		client.Transport = &cassetteTransport{
+			Dir:       cassette,
+			IsReplay:  isReplay,
+			Transport: client.Transport,
+		}
+		if isReplay {
+			// Nothing is sent to the fake Pixiv when replaying.
+			fp.Close()
+		}
+		var downloader = newTestDownloader(t, client, AJAXSource)
+		if err := downloader.Download(context.Background(), "100", "200",
+			"https://www.pixiv.net/users/1"); err != nil {
+			t.Fatalf("isReplay: %v, %v", isReplay, err)
+		}
+		if !isReplay {
+			recordTree = getTree(t, downloader.Path)
+		} else if tree := getTree(t, downloader.Path); !reflect.DeepEqual(
+			tree, recordTree) {
+			t.Errorf("replayed files %v are not recorded files %v",
+				tree, recordTree)
+		}
+	}
+	
+	// A request that is not recorded is an error, and it is not sent
+	// again.
+	var (
+		client = &Client{
+			Client: &http.Client{Transport: &cassetteTransport{
+				Dir: cassette, IsReplay: true}},
+			MaxRetries: 3,
+			retryWait:  time.Second,
+		}
+		start  = time.Now()
+		_, err = client.Get(PixivHomeURL + "not_recorded")
+	)
+	if err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("error %v does not contain \"no recorded response\"", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("a request that is not recorded is retried for %v",
+			elapsed)
+	}
+}
+
+// TestCassetteTransportRedact check the session of logging in is not saved
+// to cassette files, but it is still kept by the cookie jar.
+func TestCassetteTransportRedact(t *testing.T) {
+	var (
+		fp       = newFakePixiv(t)
+		session  = NewSession(fp.client(false))
+		cassette = t.TempDir()
+		isLogin  bool
+		names    []string
+		err      error
+	)
+	session.Client.Transport = &cassetteTransport{
+		Dir: cassette, Transport: session.Client.Transport}
+	if err = session.Login(context.Background(),
+		fakeUsername, fakePassword); err != nil {
+		t.Fatal(err)
+	}
+	if isLogin, err = session.IsLoggedIn(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if !isLogin {
+		t.Error("the session is not kept by the cookie jar")
+	}
+	
+	if names, err = filepath.Glob(filepath.Join(cassette, "*")); err != nil {
+		t.Fatal(err)
+	} else if len(names) == 0 {
+		t.Fatal("no response is recorded")
+	}
+	for _, name := range names {
+		var data []byte
+		if data, err = ioutil.ReadFile(name); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), fakeSession) {
+			t.Errorf("the session is saved in %q", filepath.Base(name))
+		}
+	}
+}
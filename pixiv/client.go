@@ -0,0 +1,446 @@
+package pixiv
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	
+	"github.com/juju/persistent-cookiejar"
+)
+
+// A Client is an HTTP client. Its zero value (DefaultClient) is a
+// usable client that uses DefaultTransport.
+//
+// The Client's Transport typically has internal state (cached TCP
+// connections), so Clients should be reused instead of created as
+// needed. Clients are safe for concurrent use by multiple goroutines.
+//
+// A Client is higher-level than a RoundTripper (such as Transport)
+// and additionally handles HTTP details such as cookies and
+// redirects.
+//
+// When following redirects, the Client will forward all headers set on the
+// initial Request except:
+//
+// • when forwarding sensitive headers like "Authorization",
+// "WWW-Authenticate", and "Cookie" to untrusted targets.
+// These headers will be ignored when following a redirect to a domain
+// that is not a subdomain match or exact match of the initial domain.
+// For example, a redirect from "foo.com" to either "foo.com" or "sub.foo.com"
+// will forward the sensitive headers, but a redirect to "bar.com" will not.
+//
+// • when forwarding the "Cookie" header with a non-nil cookie Jar.
+// Since each redirect may mutate the state of the cookie jar,
+// a redirect may possibly alter a cookie set in the initial request.
+// When forwarding the "Cookie" header, any mutated cookies will be omitted,
+// with the expectation that the Jar will insert those mutated cookies
+// with the updated values (assuming the origin matches).
+// If Jar is nil, the initial cookies are forwarded without change.
+//
+type Client struct {
+	*http.Client                `ini:"-"`
+	UserAgent       string      `ini:",omitempty"`
+	MaxConnsPerHost int         `ini:",omitempty"`
+	MaxRetries      int         `ini:",omitempty"`
+	RetryWait       string      `ini:",omitempty"`
+	MaxRetryWait    string      `ini:",omitempty"`
+	// The max time of waiting for response headers of a request, and the
+	// max time of running a command by WithTotalTimeout, empty means no
+	// limit.
+	RequestTimeout  string      `ini:",omitempty"`
+	TotalTimeout    string      `ini:",omitempty"`
+	// Requests per second to pages and images, and bytes per second of
+	// images, 0 means no limit.
+	PageRate        float64     `ini:",omitempty"`
+	ImageRate       float64     `ini:",omitempty"`
+	ImageByteRate   float64     `ini:",omitempty"`
+	// The proxy like "http://host:port" or "socks5://host:port" and hosts
+	// that do not use it split by ",", environment variables are used if
+	// they are empty.
+	Proxy           string      `ini:",omitempty"`
+	ProxyUser       string      `ini:",omitempty"`
+	ProxyPassword   string      `ini:",omitempty"`
+	NoProxy         string      `ini:",omitempty"`
+	// Folders to record responses to or replay responses from, only one
+	// of them can be set.
+	Record          string      `ini:",omitempty"`
+	Replay          string      `ini:",omitempty"`
+	// The file to save requests and responses in HAR for debugging.
+	HAR             string      `ini:"-"`
+	// The file that the cookie jar is loaded from and saved to, the
+	// cookie jar is not saved if it is empty.
+	CookieFile      string      `ini:"-"`
+	// The logger of retries and results of downloading, nothing is
+	// logged if it is nil.
+	Logger          *log.Logger `ini:"-"`
+	
+	retryWait, maxRetryWait, totalTimeout       time.Duration
+	requestTimeout                              time.Duration
+	pageLimiter, imageLimiter, imageByteLimiter *rateLimiter
+	har                                         *harTransport
+}
+
+// NewClient make a Client with default values, it should be initialized
+// by Client.Init before sending requests.
+func NewClient() *Client {
+	return &Client{
+		UserAgent:       GetUserAgent(),
+		MaxConnsPerHost: 4,
+		MaxRetries:      3,
+		RetryWait:       "1s",
+		MaxRetryWait:    "30s",
+		RequestTimeout:  "2m",
+		PageRate:        2,
+		ImageRate:       5,
+	}
+}
+
+// Init parse durations and make rate limiters of Client, and make the
+// http.Client of Client if it is not set, with a cookie jar that load from
+// and save to Client.CookieFile, and a transport that limit connections
+// per host to Client.MaxConnsPerHost, use the proxy of Client and wait for
+// response headers at most Client.RequestTimeout. Responses are recorded
+// to Client.Record or replayed from Client.Replay if one of them is set,
+// rates are not limited when replaying. Requests and responses are saved
+// to Client.HAR by Close if it is set.
+func (c *Client) Init() (err error) {
+	var (
+		cookieJar    *cookiejar.Jar
+		transport    = http.DefaultTransport.(*http.Transport).Clone()
+		roundTripper http.RoundTripper
+	)
+	if c.Record != "" && c.Replay != "" {
+		return &AppError{Prefix: "client",
+			Msg: "\"Record\" and \"Replay\" can not be set at the same time"}
+	}
+	if c.retryWait, err = parseDuration("RetryWait", c.RetryWait); err != nil {
+		return err
+	}
+	if c.maxRetryWait, err = parseDuration(
+		"MaxRetryWait", c.MaxRetryWait); err != nil {
+		return err
+	}
+	if c.requestTimeout, err = parseDuration(
+		"RequestTimeout", c.RequestTimeout); err != nil {
+		return err
+	}
+	if c.totalTimeout, err = parseDuration(
+		"TotalTimeout", c.TotalTimeout); err != nil {
+		return err
+	}
+	if c.Replay == "" {
+		c.initRateLimiters()
+	}
+	if c.Client != nil {
+		return nil
+	}
+	if cookieJar, err = cookiejar.New(&cookiejar.Options{
+		Filename: c.CookieFile, NoPersist: c.CookieFile == ""}); err != nil {
+		return err
+	}
+	transport.MaxConnsPerHost = c.MaxConnsPerHost
+	// Reading the body is not limited, so a large image can be downloaded
+	// slowly at Client.ImageByteRate.
+	transport.ResponseHeaderTimeout = c.requestTimeout
+	if transport.Proxy, err = c.getProxy(); err != nil {
+		return err
+	}
+	roundTripper = transport
+	if c.Record != "" || c.Replay != "" {
+		roundTripper = &cassetteTransport{
+			Dir:       c.Record + c.Replay,
+			IsReplay:  c.Replay != "",
+			Transport: transport,
+		}
+	}
+	if c.HAR != "" {
+		c.har = &harTransport{Transport: roundTripper}
+		roundTripper = c.har
+	}
+	c.Client = &http.Client{Jar: cookieJar, Transport: roundTripper}
+	return nil
+}
+
+// Close save requests and responses to Client.HAR if it is set.
+func (c *Client) Close() error {
+	if c.har == nil {
+		return nil
+	}
+	return c.har.save(c.HAR)
+}
+
+// WithTotalTimeout return a copy of ctx that is canceled after
+// Client.TotalTimeout, ctx is not changed if it is not set. It should be
+// called after Init.
+func (c *Client) WithTotalTimeout(
+		ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.totalTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.totalTimeout)
+}
+
+// SaveCookie save the cookie jar to Client.CookieFile, so the session is
+// kept next time. It does nothing if Client.CookieFile is empty or the
+// cookie jar is not made by Init.
+func (c *Client) SaveCookie() error {
+	if cookieJar, ok := c.Jar.(*cookiejar.Jar); ok && c.CookieFile != "" {
+		return cookieJar.Save()
+	}
+	return nil
+}
+
+// parseDuration parse a duration like "1s" or "500ms" of a config named
+// name, an empty string means 0.
+func parseDuration(name, str string) (duration time.Duration, err error) {
+	if str == "" {
+		return 0, nil
+	}
+	if duration, err = time.ParseDuration(str); err != nil || duration < 0 {
+		return 0, &AppError{Prefix: "client",
+			Msg: "\"" + name + "\" is not a valid duration: \"" + str + "\""}
+	}
+	return duration, nil
+}
+
+// Get issues a GET to the specified URL. If the response is one of the
+// following redirect codes, Get follows the redirect after calling the
+// Client's CheckRedirect function:
+//
+//    301 (Moved Permanently)
+//    302 (Found)
+//    303 (See Other)
+//    307 (Temporary Redirect)
+//    308 (Permanent Redirect)
+//
+// An error is returned if the Client's CheckRedirect function fails
+// or if there was an HTTP protocol error. A non-2xx response doesn't
+// cause an error.
+//
+// When err is nil, resp always contains a non-nil resp.Body.
+// Caller should close resp.Body when done reading from it.
+//
+// To make a request with custom headers, use NewRequest and Client.Do.
+func (c *Client) Get(url string) (resp *http.Response, err error) {
+	return c.GetContext(context.Background(), url)
+}
+
+// GetContext is like Get, the request is canceled when ctx is done.
+func (c *Client) GetContext(ctx context.Context,
+		url string) (resp *http.Response, err error) {
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(
+		ctx, "GET", url, nil); err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Do sends an HTTP request and returns an HTTP response, following
+// policy (such as redirects, cookies, auth) as configured on the
+// client.
+//
+// An error is returned if caused by client policy (such as0
+// CheckRedirect), or failure to speak HTTP (such as a network
+// connectivity problem). A non-2xx status code doesn't cause an
+// error.
+//
+// If the returned error is nil, the Response will contain a non-nil
+// Body which the user is expected to close. If the Body is not
+// closed, the Client's underlying RoundTripper (typically Transport)
+// may not be able to re-use a persistent TCP connection to the server
+// for a subsequent "keep-alive" request.
+//
+// The request Body, if non-nil, will be closed by the underlying
+// Transport, even on errors.
+//
+// On error, any Response can be ignored. A non-nil Response with a
+// non-nil error only occurs when CheckRedirect fails, and even then
+// the returned Response.Body is already closed.
+//
+// Generally Get, Post, or PostForm will be used instead of Do.
+//
+// If the server replies with a redirect, the Client first uses the
+// CheckRedirect function to determine whether the redirect should be
+// followed. If permitted, a 301, 302, or 303 redirect causes
+// subsequent requests to use HTTP method GET
+// (or HEAD if the original request was HEAD), with no body.
+// A 307 or 308 redirect preserves the original HTTP method and body,
+// provided that the Request.GetBody function is defined.
+// The NewRequest function automatically sets GetBody for common
+// standard library body types.
+//
+// An idempotent request (GET, HEAD or OPTIONS) is sent again at most
+// Client.MaxRetries times if a network error, a 429 or a 5xx status
+// occurs. The wait before each retry is doubled from Client.RetryWait up
+// to Client.MaxRetryWait with random jitter, or longer if the server set
+// "Retry-After". Other requests like POST, and requests that are not
+// recorded when replaying, are never sent again.
+//
+// Every request including retries waits for the rate limit of its host,
+// and the body of an image is read at the rate limit of bytes.
+//
+// Waits and the request are stopped when the context of the request is
+// done, and it is not sent again after that.
+func (c *Client) Do(req *http.Request) (resp *http.Response, err error) {
+	var ctx = req.Context()
+	req.Header.Add("User-Agent", c.UserAgent)
+	req.Header.Add("Referer", PixivHomeURL)
+	for retry := 0; ; retry++ {
+		if err = c.waitRate(req); err != nil {
+			return nil, err
+		}
+		resp, err = c.Client.Do(req)
+		if retry >= c.MaxRetries || !isIdempotent(req.Method) ||
+				!isRetryable(resp, err) || ctx.Err() != nil {
+			if err == nil {
+				c.limitBody(resp)
+			}
+			return resp, err
+		}
+		
+		var wait = c.getRetryWait(retry, resp)
+		if err != nil {
+			c.logf("client", "retry \"%s\" in %v because of %v",
+				req.URL, wait, err)
+		} else {
+			c.logf("client", "retry \"%s\" in %v because of status %d",
+				req.URL, wait, resp.StatusCode)
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err = sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleep wait for the duration, it return the error of ctx if ctx is done
+// before that.
+func sleep(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return ctx.Err()
+	}
+	var timer = time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isIdempotent check the request method can be sent again safely or not.
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		return true
+	}
+	return false
+}
+
+// isRetryable check the request should be sent again or not by the result,
+// a request that is not recorded is not sent again when replaying.
+func isRetryable(resp *http.Response, err error) bool {
+	var missErr *cassetteMissError
+	if err != nil {
+		return !errors.As(err, &missErr)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests ||
+			resp.StatusCode >= http.StatusInternalServerError
+}
+
+// getRetryWait get the wait before the retry that start from 0, it is
+// doubled from Client.RetryWait and not longer than Client.MaxRetryWait,
+// then a random jitter that up to half of wait is removed. "Retry-After"
+// of the response is used if it is longer.
+func (c *Client) getRetryWait(retry int, resp *http.Response) time.Duration {
+	var wait = c.retryWait
+	for i := 0; i < retry && wait < c.maxRetryWait; i++ {
+		wait *= 2
+	}
+	if wait > c.maxRetryWait {
+		wait = c.maxRetryWait
+	}
+	if wait > 0 {
+		wait -= time.Duration(rand.Int63n(int64(wait/2) + 1))
+	}
+	if resp != nil {
+		if retryAfter := getRetryAfter(resp); retryAfter > wait {
+			wait = retryAfter
+		}
+	}
+	return wait
+}
+
+// getRetryAfter get the wait from "Retry-After" of the response, that is
+// seconds or an HTTP date.
+func getRetryAfter(resp *http.Response) time.Duration {
+	var retryAfter = resp.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(retryAfter); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// Post issues a POST to the specified URL.
+//
+// Caller should close resp.Body when done reading from it.
+//
+// If the provided body is an io.Closer, it is closed after the
+// request.
+//
+// To set custom headers, use NewRequest and Client.Do.
+//
+// See the Client.Do method documentation for details on how redirects
+// are handled.
+func (c *Client) Post(url string, contentType string, body io.Reader) (resp *http.Response, err error) {
+	return c.PostContext(context.Background(), url, contentType, body)
+}
+
+// PostContext is like Post, the request is canceled when ctx is done.
+func (c *Client) PostContext(ctx context.Context, url string,
+		contentType string, body io.Reader) (resp *http.Response, err error) {
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(
+		ctx, "POST", url, body); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.Do(req)
+}
+
+// PostForm issues a POST to the specified URL,
+// with data's keys and values URL-encoded as the request body.
+//
+// The Content-Type header is set to application/x-www-form-urlencoded.
+// To set other headers, use NewRequest and DefaultClient.Do.
+//
+// When err is nil, resp always contains a non-nil resp.Body.
+// Caller should close resp.Body when done reading from it.
+//
+// See the Client.Do method documentation for details on how redirects
+// are handled.
+func (c *Client) PostForm(url string, data url.Values) (resp *http.Response, err error) {
+	return c.PostFormContext(context.Background(), url, data)
+}
+
+// PostFormContext is like PostForm, the request is canceled when ctx is
+// done.
+func (c *Client) PostFormContext(ctx context.Context, url string,
+		data url.Values) (resp *http.Response, err error) {
+	return c.PostContext(ctx, url, "application/x-www-form-urlencoded",
+		strings.NewReader(data.Encode()))
+}
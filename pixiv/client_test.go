@@ -0,0 +1,193 @@
+package pixiv
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient make an initialized Client with short waits of retry that
+// send requests to server.
+func newTestClient(t *testing.T, server *httptest.Server) *Client {
+	var client = &Client{
+		Client:       server.Client(),
+		MaxRetries:   3,
+		RetryWait:    "1ms",
+		MaxRetryWait: "10ms",
+	}
+	if err := client.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// TestClientDoRetry check idempotent requests are sent again at most
+// Client.MaxRetries times if the status is 429 or 5xx.
+func TestClientDoRetry(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		statuses []int
+		status   int
+		count    int32
+	}{
+		{"OK", []int{200}, 200, 1},
+		{"Recovered", []int{503, 500, 200}, 200, 3},
+		{"TooManyRequests", []int{429, 200}, 200, 2},
+		{"AlwaysFailed", []int{500, 500, 500, 500, 500}, 500, 4},
+		{"NotFound", []int{404, 200}, 404, 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				count  int32
+				server = httptest.NewServer(http.HandlerFunc(
+					func(w http.ResponseWriter, req *http.Request) {
+						var i = atomic.AddInt32(&count, 1) - 1
+						w.WriteHeader(test.statuses[i])
+					}))
+			)
+			defer server.Close()
+			var resp, err = newTestClient(t, server).Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.status || count != test.count {
+				t.Errorf("status is %d after %d request(s), not %d after %d",
+					resp.StatusCode, count, test.status, test.count)
+			}
+		})
+	}
+}
+
+// TestClientDoRetryAfter check the wait before retry is not shorter than
+// "Retry-After" of the response.
+func TestClientDoRetryAfter(t *testing.T) {
+	var (
+		count  int32
+		server = httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, req *http.Request) {
+				if atomic.AddInt32(&count, 1) == 1 {
+					w.Header().Set("Retry-After", "1")
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+		start = time.Now()
+	)
+	defer server.Close()
+	var resp, err = newTestClient(t, server).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); resp.StatusCode != http.StatusOK ||
+			elapsed < time.Second {
+		t.Errorf("status is %d after %v, not %d after %v",
+			resp.StatusCode, elapsed, http.StatusOK, time.Second)
+	}
+}
+
+// TestClientDoNotRetryPost check a POST request is never sent again.
+func TestClientDoNotRetryPost(t *testing.T) {
+	var (
+		count  int32
+		server = httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, req *http.Request) {
+				atomic.AddInt32(&count, 1)
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+	)
+	defer server.Close()
+	var resp, err = newTestClient(t, server).PostForm(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if count != 1 {
+		t.Errorf("POST is sent %d times, not once", count)
+	}
+}
+
+// A statusTransport replace the status of responses of POST requests,
+// like the server is failed after the request is received.
+type statusTransport struct {
+	http.RoundTripper
+	status int
+}
+
+// RoundTrip is needed when implement a http.RoundTripper interface.
+func (st *statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var resp, err = st.RoundTripper.RoundTrip(req)
+	if err == nil && req.Method == "POST" {
+		resp.StatusCode = st.status
+	}
+	return resp, err
+}
+
+// TestSessionLoginNotRetried check the POST request of login is not sent
+// again even if the server is failed.
+func TestSessionLoginNotRetried(t *testing.T) {
+	var (
+		fp     = newFakePixiv(t)
+		client = fp.client(false)
+		posts  int
+	)
+	client.MaxRetries = 3
+	client.Transport = &statusTransport{RoundTripper: client.Transport,
+		status: http.StatusServiceUnavailable}
+	var err = NewSession(client).Login(context.Background(),
+		fakeUsername, fakePassword)
+	if err == nil || !strings.Contains(err.Error(), "when logging in") {
+		t.Errorf("error %v does not contain \"when logging in\"", err)
+	}
+	for _, request := range fp.getRequests(false) {
+		if strings.HasPrefix(request, "POST ") {
+			posts++
+		}
+	}
+	if posts != 1 {
+		t.Errorf("login is sent %d times, not once", posts)
+	}
+}
+
+// TestClientRequestTimeout check Client.RequestTimeout limit the wait for
+// response headers, but not reading the body.
+func TestClientRequestTimeout(t *testing.T) {
+	var server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/slow-headers" {
+				time.Sleep(200 * time.Millisecond)
+			}
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			time.Sleep(200 * time.Millisecond)
+			w.Write([]byte("body"))
+		}))
+	defer server.Close()
+	clearProxyEnv(t)
+	var client = NewClient()
+	client.RequestTimeout, client.MaxRetries = "100ms", 0
+	if err := client.Init(); err != nil {
+		t.Fatal(err)
+	}
+	
+	var resp, err = client.Get(server.URL + "/slow-body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body []byte
+	body, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "body" {
+		t.Errorf("body is %q with error %v", body, err)
+	}
+	
+	if resp, err = client.Get(server.URL + "/slow-headers"); err == nil {
+		resp.Body.Close()
+		t.Error("waiting for slow response headers is not timed out")
+	}
+}
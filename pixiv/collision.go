@@ -0,0 +1,260 @@
+package pixiv
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SkipCollision skip downloading if the file exists.
+	SkipCollision = "skip"
+	// OverwriteCollision overwrite the file if it exists, but save to a new
+	// name like RenameCollision if it is saved by other pages of the same
+	// run.
+	OverwriteCollision = "overwrite"
+	// RenameCollision save to a new name with a suffix like " (1)"
+	// if the file exists.
+	RenameCollision = "rename"
+	// HashCollision skip downloading if the file or a renamed file of it
+	// like " (1)" has same hash, otherwise save to a new name like
+	// RenameCollision.
+	HashCollision = "hash"
+)
+
+// checkCollisionPolicy check the collision policy is valid or not.
+func checkCollisionPolicy(policy string) error {
+	switch policy {
+	case SkipCollision, OverwriteCollision, RenameCollision, HashCollision:
+		return nil
+	}
+	return &AppError{
+		Prefix: "collision",
+		Msg: "policy \"" + policy + "\" is not \"" + SkipCollision +
+				"\", \"" + OverwriteCollision + "\", \"" + RenameCollision +
+				"\" or \"" + HashCollision + "\"",
+	}
+}
+
+// isFileExist check the file exists or not.
+func isFileExist(filename string) (bool, error) {
+	var _, err = os.Stat(filename)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// isFileExist check the file exists or is being saved by other pages.
+func (d *Downloader) isFileExist(filename string) (bool, error) {
+	if d.saving[filename] {
+		return true, nil
+	}
+	return isFileExist(filename)
+}
+
+// getRenamedFilename get a filename that does not exist by adding
+// a suffix like " (1)" before the extension of filename.
+func (d *Downloader) getRenamedFilename(filename string) (_ string, err error) {
+	var (
+		ext     = filepath.Ext(filename)
+		base    = strings.TrimSuffix(filename, ext)
+		isExist = true
+	)
+	for i := 1; ; i++ {
+		var renamed = base + " (" + strconv.Itoa(i) + ")" + ext
+		if isExist, err = d.isFileExist(renamed); err != nil {
+			return "", err
+		} else if !isExist {
+			return renamed, nil
+		}
+	}
+}
+
+// findSameHashFile find the file that has the same hash as dataHash in
+// filename and the renamed filenames of it that getRenamedFilename would
+// check, it return the filename that has the same hash, or an empty one and
+// the renamed filename that does not exist if no file has the same hash.
+func (d *Downloader) findSameHashFile(filename string,
+		dataHash []byte) (same, renamed string, err error) {
+	var (
+		ext     = filepath.Ext(filename)
+		base    = strings.TrimSuffix(filename, ext)
+		isExist bool
+		hash    []byte
+	)
+	for i := 0; ; i++ {
+		var name = filename
+		if i > 0 {
+			name = base + " (" + strconv.Itoa(i) + ")" + ext
+		}
+		if isExist, err = d.isFileExist(name); err != nil {
+			return "", "", err
+		} else if !isExist {
+			return "", name, nil
+		}
+		// The file that is being saved by other pages is treated as
+		// different.
+		if d.saving[name] {
+			continue
+		}
+		if hash, err = getFileHash(name); os.IsNotExist(err) {
+			err = nil
+		} else if err != nil {
+			return "", "", err
+		} else if bytes.Equal(hash, dataHash) {
+			return name, "", nil
+		}
+	}
+}
+
+// getFileHash get the SHA-256 hash of a file.
+func getFileHash(filename string) (_ []byte, err error) {
+	var (
+		file *os.File
+		hash = sha256.New()
+	)
+	if file, err = os.Open(filename); err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, err = io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// resolveCollision decide where the downloaded data will be saved when
+// the file exists by the collision policy, it return an empty filename
+// if the data should not be saved. The SHA-256 hash of data is only needed
+// by the hash policy. Every decision is logged. A filename that is being
+// saved by other pages is treated as existing, the returned filename must
+// be released by releaseFilename after it is saved.
+func (d *Downloader) resolveCollision(filename string,
+		dataHash []byte) (_ string, err error) {
+	d.savingMutex.Lock()
+	defer d.savingMutex.Unlock()
+	if d.saving == nil {
+		d.saving = make(map[string]bool)
+	}
+	if filename, err = d.resolveExistingFile(
+		filename, dataHash); err == nil && filename != "" {
+		d.saving[filename] = true
+	}
+	return filename, err
+}
+
+// A partDownload is a partial file that is being downloaded by a page,
+// filename is the file that the image is saved to, which is set before
+// done is closed.
+type partDownload struct {
+	done     chan struct{}
+	filename string
+}
+
+// reservePart reserve a partial file for a page, it wait until the partial
+// file is released by other pages that are downloading it. It return the
+// file that the image is saved to by other pages, which is empty if no
+// page saved it, so the image does not need to be downloaded again. The
+// partial file must be released by releasePart.
+func (d *Downloader) reservePart(ctx context.Context,
+		part string) (saved string, err error) {
+	for {
+		d.savingMutex.Lock()
+		if d.parts == nil {
+			d.parts = make(map[string]*partDownload)
+		}
+		var pd = d.parts[part]
+		if pd == nil {
+			d.parts[part] = &partDownload{done: make(chan struct{})}
+			d.savingMutex.Unlock()
+			return saved, nil
+		}
+		d.savingMutex.Unlock()
+		select {
+		case <-pd.done:
+			if pd.filename != "" {
+				saved = pd.filename
+			}
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// releasePart release a partial file reserved by reservePart, filename is
+// the file that the image is saved to, or empty if it is not saved.
+func (d *Downloader) releasePart(part, filename string) {
+	d.savingMutex.Lock()
+	var pd = d.parts[part]
+	delete(d.parts, part)
+	d.savingMutex.Unlock()
+	pd.filename = filename
+	close(pd.done)
+}
+
+// releaseFilename release a filename returned by resolveCollision.
+func (d *Downloader) releaseFilename(filename string) {
+	d.savingMutex.Lock()
+	defer d.savingMutex.Unlock()
+	delete(d.saving, filename)
+}
+
+// resolveExistingFile decide where the downloaded data will be saved for
+// resolveCollision.
+func (d *Downloader) resolveExistingFile(filename string,
+		dataHash []byte) (_ string, err error) {
+	var (
+		isExist bool
+		renamed string
+	)
+	
+	if isExist, err = d.isFileExist(filename); err != nil || !isExist {
+		return filename, err
+	}
+	
+	switch d.Collision {
+	case SkipCollision:
+		d.Client.logf("download", "skip \"%s\" because it exists", filename)
+		return "", nil
+	case OverwriteCollision:
+		// A file that is being saved by other pages in this run is renamed
+		// like RenameCollision, so pages do not overwrite each other.
+		if !d.saving[filename] {
+			d.Client.logf("download", "overwrite \"%s\" because it exists",
+				filename)
+			return filename, nil
+		}
+	case HashCollision:
+		var same string
+		// The renamed files of previous runs are also compared, so the same
+		// data is not saved again with another suffix.
+		if same, renamed, err = d.findSameHashFile(
+			filename, dataHash); err != nil {
+			return "", err
+		} else if same == filename {
+			d.Client.logf("download", "skip \"%s\" because it exists with "+
+					"same hash", filename)
+			return "", nil
+		} else if same != "" {
+			d.Client.logf("download", "skip \"%s\" because \"%s\" exists "+
+					"with same hash", filename, same)
+			return "", nil
+		}
+		d.Client.logf("download", "save to \"%s\" because \"%s\" exists with "+
+				"different hash", renamed, filename)
+		return renamed, nil
+	}
+	
+	if renamed, err = d.getRenamedFilename(filename); err != nil {
+		return "", err
+	}
+	d.Client.logf("download", "save to \"%s\" because \"%s\" exists",
+		renamed, filename)
+	return renamed, nil
+}
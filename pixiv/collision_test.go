@@ -0,0 +1,121 @@
+package pixiv
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// TestResolveCollision check where data is saved by each collision policy
+// when the file exists, or is being saved by another page of the same run.
+func TestResolveCollision(t *testing.T) {
+	var dir = t.TempDir()
+	for _, name := range []string{"100.jpg", "100 (1).jpg"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name),
+			[]byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		name     string
+		policy   string
+		filename string
+		isSaving bool
+		want     string
+	}{
+		{"SkipNotExist", SkipCollision, "200.jpg", false, "200.jpg"},
+		{"SkipExist", SkipCollision, "100.jpg", false, ""},
+		{"SkipSaving", SkipCollision, "200.jpg", true, ""},
+		{"OverwriteNotExist", OverwriteCollision, "200.jpg", false,
+			"200.jpg"},
+		{"OverwriteExist", OverwriteCollision, "100.jpg", false, "100.jpg"},
+		{"OverwriteSaving", OverwriteCollision, "200.jpg", true,
+			"200 (1).jpg"},
+		{"RenameNotExist", RenameCollision, "200.jpg", false, "200.jpg"},
+		{"RenameExist", RenameCollision, "100.jpg", false, "100 (2).jpg"},
+		{"RenameSaving", RenameCollision, "200.jpg", true, "200 (1).jpg"},
+		{"HashSaving", HashCollision, "200.jpg", true, "200 (1).jpg"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				d = &Downloader{DownloadOptions: DownloadOptions{
+					Collision: test.policy,
+				}}
+				dataHash = sha256.Sum256([]byte(test.name))
+				filename = filepath.Join(dir, test.filename)
+				resolved string
+				err      error
+			)
+			// Another page of the same run is saving the same name.
+			if test.isSaving {
+				if resolved, err = d.resolveCollision(filename,
+					dataHash[:]); err != nil {
+					t.Fatal(err)
+				} else if resolved != filename {
+					t.Fatalf("%q is not reserved", test.filename)
+				}
+			}
+			if resolved, err = d.resolveCollision(filename,
+				dataHash[:]); err != nil {
+				t.Fatal(err)
+			}
+			if resolved != "" {
+				resolved = filepath.Base(resolved)
+			}
+			if resolved != test.want {
+				t.Errorf("%q is saved to %q, not %q", test.filename,
+					resolved, test.want)
+			}
+		})
+	}
+}
+
+// TestResolveCollisionHash check the hash policy skip the data that is
+// saved to the file or the renamed files of it by previous runs, and save
+// other data to the next renamed file.
+func TestResolveCollisionHash(t *testing.T) {
+	var (
+		dir = t.TempDir()
+		d   = &Downloader{DownloadOptions: DownloadOptions{
+			Collision: HashCollision,
+		}}
+	)
+	for name, data := range map[string]string{
+		"100.jpg":     "a",
+		"100 (1).jpg": "b",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name),
+			[]byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Each data is saved if it is not skipped, like running again.
+	for _, test := range [][2]string{
+		{"a", ""},
+		{"b", ""},
+		{"c", "100 (2).jpg"},
+		{"c", ""},
+		{"d", "100 (3).jpg"},
+	} {
+		var dataHash = sha256.Sum256([]byte(test[0]))
+		var filename, err = d.resolveCollision(
+			filepath.Join(dir, "100.jpg"), dataHash[:])
+		if err != nil {
+			t.Fatal(err)
+		} else if filename == "" {
+			if test[1] != "" {
+				t.Errorf("%q is skipped, not saved to %q", test[0], test[1])
+			}
+			continue
+		}
+		d.releaseFilename(filename)
+		if filepath.Base(filename) != test[1] {
+			t.Errorf("%q is saved to %q, not %q", test[0],
+				filepath.Base(filename), test[1])
+		} else if err = ioutil.WriteFile(filename,
+			[]byte(test[0]), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
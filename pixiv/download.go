@@ -0,0 +1,449 @@
+package pixiv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultDownloadOptions get DownloadOptions with default values.
+func DefaultDownloadOptions() DownloadOptions {
+	return DownloadOptions{
+		Path: "./",
+		Naming: Naming{
+			NamingRule: NamingRule{
+				SingleFile:   "<artist.name>/(<work.id>) <work.name>",
+				MultipleFile: "<work.page>",
+				Folder:       "<artist.name>/(<work.id>) <work.name>",
+			},
+		},
+		Sanitize:  WindowsPolicy,
+		Collision: SkipCollision,
+		Works:     2,
+		Pages:     2,
+		Metadata:  "",
+		Source:    AJAXSource,
+	}
+}
+
+// A DownloadOptions save options of Downloader, Path is the folder that
+// works are downloaded to, and Works and Pages are the max numbers of works
+// and pages that are downloaded at the same time.
+type DownloadOptions struct {
+	Path      string
+	Naming    Naming
+	Sanitize  string
+	Collision string
+	Works     int
+	Pages     int
+	Metadata  string
+	// The name of MetadataSource, and the folder of files for
+	// FixtureSource.
+	Source     string
+	FixtureDir string
+}
+
+// A Downloader download works from Pixiv by Client with DownloadOptions.
+type Downloader struct {
+	Client *Client
+	DownloadOptions
+	
+	source      MetadataSource
+	saving      map[string]bool
+	parts       map[string]*partDownload
+	savingMutex sync.Mutex
+}
+
+// NewDownloader make a Downloader of the client with options, options are
+// checked by DownloadOptions.Check.
+func NewDownloader(client *Client,
+		options DownloadOptions) (_ *Downloader, err error) {
+	var d = &Downloader{Client: client, DownloadOptions: options}
+	if err = d.Check(); err != nil {
+		return nil, err
+	}
+	d.source = d.newMetadataSource()
+	return d, nil
+}
+
+// A Naming save naming patterns of downloaded files. Patterns for a type
+// of work can be set in its NamingRule like Manga, and the patterns that
+// are not set fall back to the embedded NamingRule.
+type Naming struct {
+	NamingRule
+	Illust *NamingRule
+	Ugoira *NamingRule
+	Manga  *NamingRule
+}
+
+// A NamingRule save naming patterns of single file, multiple files and
+// the folder of multiple files.
+type NamingRule struct {
+	SingleFile   string
+	MultipleFile string
+	Folder       string
+	
+	singleFile, multipleFile, folder *namingPattern
+}
+
+// ArtistData save the data of a artist.
+type ArtistData struct {
+	ID       string `tag:"artist.id" json:"id,omitempty"`                         // `href="/member.php?id=(\d+?)" class="tab-profile"`
+	Username string `tag:"artist.username" json:"username,omitempty"`             // `href="/stacc/(.+?)" class="tab-feed"`
+	Nickname string `tag:"artist.nickname,artist.name" json:"nickname,omitempty"` // `<span class="user-name">(.+?)</span>`
+}
+
+// A WorkType resolve the type of a work.
+type WorkType uint8
+
+const (
+	Illust WorkType = iota
+	Ugoira
+	Manga
+)
+
+// String get the name of WorkType.
+func (wt WorkType) String() string {
+	switch wt {
+	case Illust:
+		return "illust"
+	case Ugoira:
+		return "ugoira"
+	case Manga:
+		return "manga"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText make WorkType be its name in JSON.
+func (wt WorkType) MarshalText() ([]byte, error) {
+	return []byte(wt.String()), nil
+}
+
+// UnmarshalText get WorkType from its name in JSON.
+func (wt *WorkType) UnmarshalText(text []byte) error {
+	for _, t := range []WorkType{Illust, Ugoira, Manga} {
+		if string(text) == t.String() {
+			*wt = t
+			return nil
+		}
+	}
+	return throw("download", "work type \""+ string(text)+ "\" is unknown")
+}
+
+// A WorkData save the data of a work.
+type WorkData struct {
+	ID        string     `tag:"work.id" json:"id"`
+	Name      string     `tag:"work.name" json:"name,omitempty"`
+	Time      time.Time  `tag:"work.time" json:"time,omitempty"`
+	PageCount uint64     `tag:"work.page_count" json:"page_count,omitempty"`
+	Tools     []string   `tag:"work.tools" json:"tools,omitempty"`
+	Series    string     `tag:"work.series" json:"series,omitempty"`
+	Caption   string     `tag:"work.caption" naming:"-" json:"caption,omitempty"`
+	Tags      []string   `tag:"work.tags" json:"tags,omitempty"`
+	Type      WorkType   `tag:"work.type" json:"type"`
+	Pages     []PageData `tag:"work.pages" naming:"-" json:"pages,omitempty"`
+	Thumb     string     `tag:"work.thumb" naming:"-" json:"thumb,omitempty"`
+}
+
+// A PageData save the data of a page of a work.
+type PageData struct {
+	Page     uint64 `tag:"page,work.page" json:"page"`
+	Width    uint64 `tag:"width" json:"width,omitempty"`
+	Height   uint64 `tag:"height" json:"height,omitempty"`
+	Filename string `tag:"filename" json:"filename,omitempty"`
+	ImageURL string `tag:"url" naming:"-" json:"url"`
+}
+
+// Download download works from inputs in order, each input is a work ID,
+// a URL of work, image or user in Pixiv, the filename of a list, or
+// StdinInput. All inputs are resolved and works of users and lists are
+// gotten before downloading. A work that failed does not stop other works,
+// errors of all failed works are returned as an ErrorList.
+func (d *Downloader) Download(ctx context.Context,
+		inputs ...string) (err error) {
+	var (
+		isLoggedIn     bool
+		resolvedInputs []*Input
+		items          []*listItem
+	)
+	
+	// Resolve inputs before downloading.
+	if resolvedInputs, err = resolveInputs(inputs); err != nil {
+		return err
+	}
+	
+	// Check that pixiv is already logged or not.
+	if isLoggedIn, err = NewSession(d.Client).isLoggedIn(ctx, "download",
+		"request status is not OK when checking "+
+				"that not login yet or not"); err != nil {
+		return err
+	} else if !isLoggedIn {
+		return throw("download", "not logged in yet")
+	}
+	
+	// Get works of each input and download them.
+	if items, err = d.getItems(ctx, resolvedInputs); err != nil {
+		return err
+	}
+	return d.downloadItems(ctx, items)
+}
+
+// Check compile naming patterns and check the sanitize policy, the
+// collision policy and the metadata source.
+func (o *DownloadOptions) Check() (err error) {
+	if err = o.Naming.compile(); err != nil {
+		return err
+	}
+	if err = checkSanitizePolicy(o.Sanitize); err != nil {
+		return err
+	}
+	if err = checkCollisionPolicy(o.Collision); err != nil {
+		return err
+	}
+	return checkMetadataSource(o.Source)
+}
+
+// getItems get works of inputs in order, works of users and lists are
+// gotten before downloading.
+func (d *Downloader) getItems(ctx context.Context,
+		inputs []*Input) (items []*listItem, err error) {
+	for _, input := range inputs {
+		var inputItems []*listItem
+		switch input.Kind {
+		case WorkInput:
+			inputItems = []*listItem{getItemFromID(input.ID)}
+		case UserInput:
+			inputItems, err = d.getItemsFromUser(ctx, input.ID)
+		case ListInput:
+			inputItems, err = d.getItemsFromList(ctx, input.ID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, inputItems...)
+	}
+	return items, nil
+}
+
+// getItemFromID get a work from given Pixiv work ID.
+func getItemFromID(workID string) *listItem {
+	// Before calling Downloader.download, workData should include ID value.
+	return &listItem{Artist: new(ArtistData), Work: &WorkData{ID: workID}}
+}
+
+// getItemsFromUser get all works of given Pixiv user ID.
+func (d *Downloader) getItemsFromUser(ctx context.Context,
+		userID string) (items []*listItem, err error) {
+	var workIDs []string
+	if workIDs, err = d.getUserWorkIDs(ctx, userID); err != nil {
+		return nil, err
+	}
+	for _, workID := range workIDs {
+		items = append(items, getItemFromID(workID))
+	}
+	return items, nil
+}
+
+// downloadItems download works with at most DownloadOptions.Works works
+// at the same time, and log the result of each work in order. Other works
+// are still downloaded after a work failed, only works that are not
+// started when ctx is done are skipped. A summary of works is logged at
+// last, works that are interrupted because ctx is done are counted apart
+// from failed works.
+func (d *Downloader) downloadItems(ctx context.Context,
+		items []*listItem) (err error) {
+	var downloaded, failed, interrupted int
+	err = runJobs(ctx, len(items), d.Works, func(i int) error {
+		return d.download(ctx, items[i])
+	}, func(i int, err error) {
+		switch {
+		case err == nil:
+			downloaded++
+			d.Client.logf("download", "work \"%s\" is downloaded",
+				items[i].Work.ID)
+		case ctx.Err() != nil && errors.Is(err, ctx.Err()):
+			interrupted++
+			d.Client.logf("download", "work \"%s\" is interrupted",
+				items[i].Work.ID)
+		default:
+			failed++
+			d.Client.logf("download", "failed to download work \"%s\": %v",
+				items[i].Work.ID, err)
+		}
+	})
+	d.Client.logf("download", "%d of %d work(s) are downloaded, %d failed, "+
+			"%d interrupted and %d not started", downloaded, len(items),
+		failed, interrupted, len(items)-downloaded-failed-interrupted)
+	return err
+}
+
+// getUserWorkIDs get IDs of all illusts and manga of a user, from the
+// oldest to the newest.
+func (d *Downloader) getUserWorkIDs(ctx context.Context,
+		userID string) (workIDs []string, err error) {
+	var profile struct {
+		Illusts json.RawMessage `json:"illusts"`
+		Manga   json.RawMessage `json:"manga"`
+	}
+	
+	if err = getAJAXBody(ctx, d.Client, fmt.Sprintf(PixivUserWorksURL, userID),
+		"works of user \""+ userID+ "\"", &profile); err != nil {
+		return nil, err
+	}
+	
+	// Works are an object with IDs as keys, or an empty array if no works.
+	for _, works := range []json.RawMessage{
+		profile.Illusts, profile.Manga} {
+		var ids map[string]json.RawMessage
+		if len(works) == 0 || works[0] != '{' {
+			continue
+		}
+		if err = json.Unmarshal(works, &ids); err != nil {
+			return nil, err
+		}
+		for id := range ids {
+			workIDs = append(workIDs, id)
+		}
+	}
+	sort.Slice(workIDs, func(i, j int) bool {
+		if len(workIDs[i]) != len(workIDs[j]) {
+			return len(workIDs[i]) < len(workIDs[j])
+		}
+		return workIDs[i] < workIDs[j]
+	})
+	
+	return workIDs, nil
+}
+
+// download get data of work and artist of item that are not given and
+// download selected pages of work, all pages are downloaded if the
+// selection of item is nil.
+func (d *Downloader) download(ctx context.Context,
+		item *listItem) (err error) {
+	var artistData, workData = item.Artist, item.Work
+	// Get data of work and artist if data given by list file is not
+	// enough.
+	if !d.isWorkFilled(item) {
+		if err = d.fetchWorkData(ctx, item); err != nil {
+			return err
+		}
+	}
+	
+	// Download selected pages to the path made from naming patterns, with
+	// at most DownloadOptions.Pages pages at the same time.
+	var pages []*PageData
+	for i := range workData.Pages {
+		if item.selection.has(workData.Pages[i].Page) {
+			pages = append(pages, &workData.Pages[i])
+		}
+	}
+	return runJobs(ctx, len(pages), d.Pages, func(i int) (err error) {
+		var filename string
+		if filename, err = sanitizePath(d.Path, d.Naming.getFilePath(
+			artistData, workData, pages[i], d.Sanitize),
+			d.Sanitize); err != nil {
+			return err
+		}
+		return d.downloadPage(ctx, filename, pages[i].ImageURL)
+	}, nil)
+}
+
+// fetchWorkData get data of work and artist from the MetadataSource of
+// Downloader, and set them to fields of item that are not given and not
+// filled. Pages are replaced if any of them does not have URL.
+func (d *Downloader) fetchWorkData(ctx context.Context,
+		item *listItem) (err error) {
+	var (
+		fetchedArtist *ArtistData
+		fetchedWork   *WorkData
+	)
+	
+	if fetchedArtist, fetchedWork, err =
+			d.source.FetchWork(ctx, item.Work.ID); err != nil {
+		return err
+	}
+	
+	for _, page := range item.Work.Pages {
+		if page.ImageURL == "" {
+			item.Work.Pages = nil
+			delete(item.given, "work.pages")
+			break
+		}
+	}
+	fillEmptyFields(item.Artist, fetchedArtist, item.given)
+	fillEmptyFields(item.Work, fetchedWork, item.given)
+	return nil
+}
+
+// downloadPage download an image of a page from URL to filename, the file
+// is saved by the collision policy if it exists. The image is written to
+// a partial file in the same folder and renamed to filename after it is
+// synced, so a broken download never leaves a truncated file and it can be
+// resumed next time. If ctx is done while the image is being written, the
+// partial file is kept in the same way, and an image that is written is
+// still renamed to filename. If other pages are downloading the same URL
+// to the same folder, it wait for them and copy the image they saved.
+func (d *Downloader) downloadPage(ctx context.Context,
+		filename, url string) (err error) {
+	var (
+		part     = getPartFilename(filename, url)
+		partHash []byte
+		source   string
+		saved    string
+	)
+	
+	// Only the hash policy need downloaded data to resolve collision.
+	if d.Collision != HashCollision {
+		if filename, err = d.resolveCollision(
+			filename, nil); err != nil || filename == "" {
+			return err
+		}
+		defer d.releaseFilename(filename)
+	}
+	
+	// Only one page can write to the partial file of the same URL.
+	if source, err = d.reservePart(ctx, part); err != nil {
+		return err
+	}
+	defer func() { d.releasePart(part, saved) }()
+	
+	if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	if source != "" {
+		d.Client.logf("download", "copy \"%s\" from \"%s\" because it is "+
+				"downloaded by another page", url, source)
+		err = copyToPart(part, source)
+	} else {
+		err = d.downloadToPart(ctx, part, url)
+	}
+	if err != nil {
+		return err
+	}
+	
+	if d.Collision == HashCollision {
+		if partHash, err = getFileHash(part); err != nil {
+			return err
+		}
+		if filename, err = d.resolveCollision(
+			filename, partHash); err != nil {
+			return err
+		} else if filename == "" {
+			return removePart(part)
+		}
+		defer d.releaseFilename(filename)
+	}
+	if err = os.Rename(part, filename); err != nil {
+		return err
+	}
+	saved = filename
+	return removePart(part)
+}
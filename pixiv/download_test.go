@@ -0,0 +1,347 @@
+package pixiv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	
+	"github.com/abc1236762/pixiv_tool/internal/golden"
+)
+
+// newTestDownloader make a Downloader with default options that download
+// to a temporary folder by the client, with the metadata source.
+func newTestDownloader(t *testing.T, client *Client,
+		source string) *Downloader {
+	var options = DefaultDownloadOptions()
+	options.Path = t.TempDir()
+	options.Source = source
+	var downloader, err = NewDownloader(client, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return downloader
+}
+
+func TestDownloaderDownload(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		isLoggedIn bool
+		source     string
+		idOrList   string
+	}{
+		{"AJAX", true, AJAXSource,
+			"100 https://www.pixiv.net/artworks/200"},
+		{"HTML", true, HTMLSource,
+			"https://www.pixiv.net/member_illust.php?mode=medium&illust_id=100 200"},
+		{"User", true, AJAXSource, "https://www.pixiv.net/users/1"},
+		{"Image", true, AJAXSource, "https://i.pximg.net/img-original/img/" +
+				"2018/01/02/12/30/00/200_p1.jpg"},
+		{"NotFound", true, AJAXSource, "300"},
+		{"PartlyNotFound", true, AJAXSource, "300 100 400 200"},
+		{"LoggedOut", false, AJAXSource, "100"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				fp         = newFakePixiv(t)
+				downloader = newTestDownloader(t,
+					fp.client(test.isLoggedIn), test.source)
+				g golden.File
+			)
+			g.Result(downloader.Download(context.Background(),
+				strings.Fields(test.idOrList)...))
+			// Works and pages are downloaded at the same time.
+			g.Section("requests", fp.getRequests(true)...)
+			g.Section("files", getTree(t, downloader.Path)...)
+			g.Check(t)
+		})
+	}
+}
+
+// testImageURL is the URL of the image of work 100 in the fake Pixiv.
+const testImageURL = "https://i.pximg.net/img-original/img/" +
+		"2018/01/01/00/00/00/100_p0.png"
+
+// readTestImage read the image of testImageURL in the fake Pixiv.
+func readTestImage(t *testing.T) []byte {
+	var image, err = ioutil.ReadFile(
+		filepath.Join(fakeDir, "img", "100_p0.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return image
+}
+
+// writeTestPart write data and the validator to the partial file of
+// testImageURL, like an earlier attempt of the downloader is broken.
+func writeTestPart(t *testing.T, downloader *Downloader,
+		data []byte, validator string) {
+	var (
+		folder = filepath.Join(downloader.Path, "Artist One")
+		part   = getPartFilename(filepath.Join(folder, "x"), testImageURL)
+	)
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(part, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(part+".meta",
+		[]byte(validator), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestDownloaderDownloadResume check a partial file of an image is resumed from
+// its end by "Range".
+func TestDownloaderDownloadResume(t *testing.T) {
+	var (
+		fp         = newFakePixiv(t)
+		downloader = newTestDownloader(t, fp.client(true), AJAXSource)
+		g          golden.File
+	)
+	writeTestPart(t, downloader, readTestImage(t)[:10],
+		fakeModTime.Format(http.TimeFormat))
+	
+	g.Result(downloader.Download(context.Background(), "100"))
+	g.Section("requests", fp.getRequests(true)...)
+	g.Section("files", getTree(t, downloader.Path)...)
+	g.Check(t)
+}
+
+// TestDownloaderDownloadRestart check the whole image is downloaded again
+// if a partial file of it can not be resumed.
+func TestDownloaderDownloadRestart(t *testing.T) {
+	var image = readTestImage(t)
+	for _, test := range []struct {
+		name        string
+		data        []byte
+		validator   string
+		ignoreRange bool
+		forceRange  string
+	}{
+		// The server ignore "Range" and return the whole image.
+		{"Ignored", image[:10], fakeModTime.Format(http.TimeFormat), true,
+			""},
+		// The image is changed so "If-Range" does not match.
+		{"Changed", image[:10], "Mon, 01 Jan 2001 00:00:00 GMT", false, ""},
+		// The partial file is longer than the image.
+		{"Unsatisfiable", append(append([]byte(nil), image...), "extra"...),
+			fakeModTime.Format(http.TimeFormat), false, ""},
+		// The server return a range that does not start from the end of
+		// the partial file.
+		{"Mismatched", image[:10], fakeModTime.Format(http.TimeFormat), false,
+			"bytes=5-"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				fp         = newFakePixiv(t)
+				downloader = newTestDownloader(t, fp.client(true),
+					AJAXSource)
+				filename = filepath.Join(downloader.Path, "Artist One",
+					"(100) Work 100.png")
+				data []byte
+				err  error
+				g    golden.File
+			)
+			fp.ignoreRange, fp.forceRange = test.ignoreRange, test.forceRange
+			writeTestPart(t, downloader, test.data, test.validator)
+			
+			g.Result(downloader.Download(context.Background(), "100"))
+			if data, err = ioutil.ReadFile(filename); err != nil {
+				t.Fatal(err)
+			} else if !bytes.Equal(data, image) {
+				t.Errorf("%q is %d bytes, not the whole image of %d bytes",
+					filename, len(data), len(image))
+			}
+			g.Section("requests", fp.getRequests(true)...)
+			g.Section("files", getTree(t, downloader.Path)...)
+			g.Check(t)
+		})
+	}
+}
+
+// TestDownloaderDownloadPageSameURL check a page wait for another page
+// that is downloading the same URL to the same folder, and copy the image
+// saved by it without downloading again.
+func TestDownloaderDownloadPageSameURL(t *testing.T) {
+	var (
+		fp         = newFakePixiv(t)
+		downloader = newTestDownloader(t, fp.client(true), AJAXSource)
+		image      = readTestImage(t)
+		first      = filepath.Join(downloader.Path, "first.png")
+		second     = filepath.Join(downloader.Path, "second.png")
+		part       = getPartFilename(first, testImageURL)
+		errs       = make(chan error, 1)
+		data       []byte
+		err        error
+	)
+	// Hold the partial file like the first page is downloading it.
+	if _, err = downloader.reservePart(context.Background(),
+		part); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		errs <- downloader.downloadPage(context.Background(), second,
+			testImageURL)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err = ioutil.WriteFile(first, image, 0644); err != nil {
+		t.Fatal(err)
+	}
+	downloader.releasePart(part, first)
+	
+	if err = <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if data, err = ioutil.ReadFile(second); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(data, image) {
+		t.Errorf("%q is not the image saved by the first page", second)
+	}
+	if requests := fp.getRequests(false); len(requests) > 0 {
+		t.Errorf("the image is downloaded again by %v", requests)
+	}
+	if isExist, _ := isFileExist(part); isExist {
+		t.Errorf("the partial file %q is left", part)
+	}
+}
+
+// errBrokenBody is the error of reading a brokenBody.
+var errBrokenBody = errors.New("connection is broken")
+
+// A brokenTransport break bodies of original images after 10 bytes, like
+// the connection is broken while downloading.
+type brokenTransport struct {
+	http.RoundTripper
+}
+
+// RoundTrip is needed when implement a http.RoundTripper interface.
+func (bt *brokenTransport) RoundTrip(req *http.Request) (*http.Response,
+		error) {
+	var resp, err = bt.RoundTripper.RoundTrip(req)
+	if err == nil && strings.Contains(req.URL.Path, "/img-original/") {
+		resp.Body = &brokenBody{Reader: io.LimitReader(resp.Body, 10),
+			Closer: resp.Body}
+	}
+	return resp, err
+}
+
+// A brokenBody return errBrokenBody after all data of Reader is read.
+type brokenBody struct {
+	io.Reader
+	io.Closer
+}
+
+// Read is needed when implement an io.Reader interface.
+func (bb *brokenBody) Read(p []byte) (n int, err error) {
+	if n, err = bb.Reader.Read(p); err == io.EOF {
+		err = errBrokenBody
+	}
+	return n, err
+}
+
+// TestDownloaderDownloadPageFailed check an error of reading or renaming
+// an image is returned, no truncated file is left as the image, and the
+// partial file is kept to be resumed.
+func TestDownloaderDownloadPageFailed(t *testing.T) {
+	var image = readTestImage(t)
+	for _, test := range []struct {
+		name     string
+		isBroken bool
+		isDir    bool
+		partSize int64
+	}{
+		// The connection is broken after 10 bytes.
+		{"Read", true, false, 10},
+		// The filename is a folder that can not be replaced, the whole
+		// image is kept in the partial file.
+		{"Rename", false, true, int64(len(image))},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				fp         = newFakePixiv(t)
+				client     = fp.client(true)
+				downloader = newTestDownloader(t, client, AJAXSource)
+				filename   = filepath.Join(downloader.Path, "100.png")
+				part       = getPartFilename(filename, testImageURL)
+				info       os.FileInfo
+				err        error
+			)
+			downloader.Collision = OverwriteCollision
+			if test.isBroken {
+				client.Transport = &brokenTransport{client.Transport}
+			}
+			if test.isDir {
+				if err = os.MkdirAll(filepath.Join(filename, "x"),
+					0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			
+			if err = downloader.downloadPage(context.Background(),
+				filename, testImageURL); err == nil {
+				t.Fatal("no error is returned")
+			} else if test.isBroken && !errors.Is(err, errBrokenBody) {
+				t.Errorf("error %v is not %v", err, errBrokenBody)
+			}
+			if info, err = os.Stat(filename); err == nil && !info.IsDir() {
+				t.Errorf("%q is left with %d bytes", filename, info.Size())
+			}
+			if info, err = os.Stat(part); err != nil {
+				t.Errorf("the partial file is not kept: %v", err)
+			} else if info.Size() != test.partSize {
+				t.Errorf("the partial file is %d bytes, not %d bytes",
+					info.Size(), test.partSize)
+			}
+		})
+	}
+}
+
+// A cancelTransport cancel the context of downloading when an original
+// image is requested, like Ctrl-C is pressed while downloading.
+type cancelTransport struct {
+	http.RoundTripper
+	cancel context.CancelFunc
+}
+
+// RoundTrip is needed when implement a http.RoundTripper interface.
+func (ct *cancelTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.Contains(req.URL.Path, "/img-original/") {
+		ct.cancel()
+	}
+	return ct.RoundTripper.RoundTrip(req)
+}
+
+// TestDownloaderDownloadCanceled check works are not downloaded after the
+// context is canceled, and no file is left for the interrupted image.
+func TestDownloaderDownloadCanceled(t *testing.T) {
+	var (
+		fp          = newFakePixiv(t)
+		client      = fp.client(true)
+		downloader  = newTestDownloader(t, client, AJAXSource)
+		ctx, cancel = context.WithCancel(context.Background())
+		err         error
+		g           golden.File
+	)
+	defer cancel()
+	client.Transport = &cancelTransport{
+		RoundTripper: client.Transport, cancel: cancel}
+	downloader.Works, downloader.Pages = 1, 1
+	
+	err = downloader.Download(ctx, "100", "200")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error %v is not %v", err, context.Canceled)
+	}
+	g.Result(err)
+	g.Section("requests", fp.getRequests(false)...)
+	g.Section("files", getTree(t, downloader.Path)...)
+	g.Check(t)
+}
@@ -0,0 +1,69 @@
+package pixiv_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	
+	"github.com/abc1236762/pixiv_tool/pixiv"
+)
+
+func ExampleSession_Login() {
+	var client = pixiv.NewClient()
+	// The session is loaded from and saved to the file.
+	client.CookieFile = ".cookie"
+	if err := client.Init(); err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+	
+	if err := pixiv.NewSession(client).Login(context.Background(),
+		"username", "password"); err != nil {
+		log.Fatal(err)
+	}
+	// Save the session, so it is logged in next time.
+	if err := client.SaveCookie(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func ExampleClient_FetchWork() {
+	var client = pixiv.NewClient()
+	if err := client.Init(); err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+	
+	var artist, work, err = client.FetchWork(context.Background(),
+		"12345678")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s by %s has %d page(s)\n",
+		work.Name, artist.Nickname, work.PageCount)
+}
+
+func ExampleDownloader_Download() {
+	var client = pixiv.NewClient()
+	client.CookieFile = ".cookie"
+	// Retries and results of works are logged.
+	client.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	if err := client.Init(); err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+	
+	var options = pixiv.DefaultDownloadOptions()
+	options.Path = "downloads"
+	options.Naming.SingleFile = "<artist.id>/<work.id>"
+	var downloader, err = pixiv.NewDownloader(client, options)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Inputs are work IDs, URLs of works or users, or list files.
+	if err = downloader.Download(context.Background(), "12345678",
+		"https://www.pixiv.net/users/1", "list.txt"); err != nil {
+		log.Fatal(err)
+	}
+}
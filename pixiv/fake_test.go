@@ -0,0 +1,245 @@
+package pixiv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	
+	"github.com/juju/persistent-cookiejar"
+)
+
+const (
+	// fakeDir is the folder of recorded responses of the fake Pixiv.
+	fakeDir = "testdata/fake"
+	// fakeUsername, fakePassword and fakePostKey are accepted by the fake
+	// Pixiv when logging in, and fakeSession is the session after that.
+	fakeUsername = "fake_user"
+	fakePassword = "fake-password"
+	fakePostKey  = "fake-post-key"
+	fakeSession  = "fake-session"
+)
+
+// fakeModTime is the time of images from the fake Pixiv.
+var fakeModTime = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// A fakePixiv is a server that serve recorded responses of Pixiv, requests
+// to all hosts of Pixiv are sent to it by the transport of fakePixiv.client.
+type fakePixiv struct {
+	*httptest.Server
+	t        *testing.T
+	requests []string
+	mutex    sync.Mutex
+	// ignoreRange make the fakePixiv ignore "Range" of requests and
+	// always serve whole images like some servers.
+	ignoreRange bool
+	// forceRange replace "Range" of requests of images if it is not empty,
+	// like servers that return another range.
+	forceRange string
+}
+
+// newFakePixiv start a fakePixiv that is closed when the test is done.
+func newFakePixiv(t *testing.T) *fakePixiv {
+	var fp = &fakePixiv{t: t}
+	fp.Server = httptest.NewServer(http.HandlerFunc(fp.serveHTTP))
+	t.Cleanup(fp.Close)
+	return fp
+}
+
+// client make a Client that send requests to the fakePixiv, with a cookie
+// jar in a temporary folder. The client is logged in if isLoggedIn is true.
+func (fp *fakePixiv) client(isLoggedIn bool) *Client {
+	var cookieJar, err = cookiejar.New(&cookiejar.Options{
+		Filename: filepath.Join(fp.t.TempDir(), ".cookie"),
+	})
+	if err != nil {
+		fp.t.Fatal(err)
+	}
+	if isLoggedIn {
+		var homeURL, _ = url.Parse(PixivHomeURL)
+		cookieJar.SetCookies(homeURL, []*http.Cookie{{
+			Name: "PHPSESSID", Value: fakeSession,
+			Domain: "pixiv.net", Path: "/",
+		}})
+	}
+	return &Client{
+		Client: &http.Client{
+			Jar:       cookieJar,
+			Transport: &fakeTransport{server: fp.Server},
+		},
+		UserAgent: "fake-agent",
+	}
+}
+
+// A fakeTransport send requests to the server, the host of request is kept
+// in Request.Host so the server can know which host is requested.
+type fakeTransport struct {
+	server *httptest.Server
+}
+
+// RoundTrip is needed when implement a http.RoundTripper interface.
+func (ft *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var (
+		fakeReq   = req.Clone(req.Context())
+		serverURL = ft.server.URL[len("http://"):]
+	)
+	fakeReq.URL.Scheme, fakeReq.URL.Host = "http", serverURL
+	fakeReq.Host = req.URL.Host
+	var resp, err = ft.server.Client().Transport.RoundTrip(fakeReq)
+	if resp != nil {
+		resp.Request = req
+	}
+	return resp, err
+}
+
+// log save a request in the order it is received, the password is not
+// saved.
+func (fp *fakePixiv) log(req *http.Request) {
+	var line = req.Method + " " + req.Host + req.URL.RequestURI()
+	if req.Method == "POST" {
+		req.ParseForm()
+		var form = url.Values{}
+		for key, values := range req.PostForm {
+			form[key] = values
+		}
+		if form.Get("password") != "" {
+			form.Set("password", "<redacted>")
+		}
+		line += " " + form.Encode()
+	}
+	if rangeHeader := req.Header.Get("Range"); rangeHeader != "" {
+		line += " Range: " + rangeHeader
+	}
+	fp.mutex.Lock()
+	fp.requests = append(fp.requests, line)
+	fp.mutex.Unlock()
+}
+
+// isLoggedIn check the request has the session of logged in or not.
+func (fp *fakePixiv) isLoggedIn(req *http.Request) bool {
+	var cookie, err = req.Cookie("PHPSESSID")
+	return err == nil && cookie.Value == fakeSession
+}
+
+// serveFile serve a recorded response in fakeDir, it is 404 if the file
+// does not exist.
+func (fp *fakePixiv) serveFile(w http.ResponseWriter, req *http.Request,
+		name string) {
+	var file, err = os.Open(filepath.Join(fakeDir, name))
+	if os.IsNotExist(err) {
+		if strings.HasSuffix(name, ".json") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":true,"message":"not found","body":[]}`)
+			return
+		}
+		http.NotFound(w, req)
+		return
+	} else if err != nil {
+		fp.t.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	http.ServeContent(w, req, name, fakeModTime, file)
+}
+
+// serveHTTP serve requests to hosts of Pixiv like the real Pixiv.
+func (fp *fakePixiv) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	fp.log(req)
+	switch host, reqPath := req.Host, req.URL.Path; {
+	case host == "accounts.pixiv.net" && reqPath == "/login":
+		if req.Method != "POST" {
+			fp.serveFile(w, req, "login.html")
+			return
+		}
+		req.ParseForm()
+		if req.PostForm.Get("post_key") != fakePostKey ||
+				req.PostForm.Get("pixiv_id") != fakeUsername ||
+				req.PostForm.Get("password") != fakePassword {
+			fp.serveFile(w, req, "login.html")
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "PHPSESSID",
+			Value: fakeSession, Domain: "pixiv.net", Path: "/"})
+		http.Redirect(w, req, PixivHomeURL, http.StatusFound)
+	case host == "www.pixiv.net" && reqPath == "/":
+		if fp.isLoggedIn(req) {
+			fp.serveFile(w, req, "home_logged_in.html")
+		} else {
+			fp.serveFile(w, req, "home_logged_out.html")
+		}
+	case host == "www.pixiv.net" && reqPath == "/logout.php":
+		http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: "",
+			Domain: "pixiv.net", Path: "/", MaxAge: -1})
+		http.Redirect(w, req, PixivHomeURL, http.StatusFound)
+	case host == "www.pixiv.net" && reqPath == "/member_illust.php":
+		var query = req.URL.Query()
+		if query.Get("mode") == "manga_big" {
+			fp.serveFile(w, req, "manga_"+ query.Get("illust_id")+
+					"_"+ query.Get("page")+ ".html")
+		} else {
+			fp.serveFile(w, req, "work_"+ query.Get("illust_id")+ ".html")
+		}
+	case host == "www.pixiv.net" && strings.HasPrefix(reqPath, "/ajax/"):
+		// Like "/ajax/illust/100/pages" to "ajax_illust_100_pages.json".
+		fp.serveFile(w, req, strings.ReplaceAll(
+			strings.Trim(reqPath, "/"), "/", "_")+ ".json")
+	case host == "i.pximg.net":
+		if fp.ignoreRange {
+			req.Header.Del("Range")
+		} else if fp.forceRange != "" && req.Header.Get("Range") != "" {
+			req.Header.Set("Range", fp.forceRange)
+		}
+		fp.serveFile(w, req, "img/"+ path.Base(reqPath))
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// getRequests get received requests, they are sorted if isSorted is true
+// because requests may be sent at the same time.
+func (fp *fakePixiv) getRequests(isSorted bool) []string {
+	fp.mutex.Lock()
+	defer fp.mutex.Unlock()
+	var requests = append([]string(nil), fp.requests...)
+	if isSorted {
+		sort.Strings(requests)
+	}
+	return requests
+}
+
+// getTree get all files in root with their sizes and SHA-256 hashes.
+func getTree(t *testing.T, root string) (tree []string) {
+	if err := filepath.Walk(root, func(name string, info os.FileInfo,
+		err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		var (
+			data, readErr = ioutil.ReadFile(name)
+			hash          = sha256.Sum256(data)
+			rel, _        = filepath.Rel(root, name)
+		)
+		if readErr != nil {
+			return readErr
+		}
+		tree = append(tree, fmt.Sprintf("%s %d %s", filepath.ToSlash(rel),
+			info.Size(), hex.EncodeToString(hash[:8])))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
@@ -0,0 +1,386 @@
+package pixiv
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HARBodyLimit is the max size in bytes of a body saved in HAR.
+	HARBodyLimit = 64 * 1024
+	// HARRedacted replace values of cookies and passwords in HAR.
+	HARRedacted = "<redacted>"
+)
+
+// harRedactedHeaders are headers that values are replaced by HARRedacted.
+var harRedactedHeaders = map[string]bool{
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+}
+
+// A harLog is the root of a HAR 1.2 file, types of HAR only have fields
+// used by this app.
+type harLog struct {
+	Log struct {
+		Version string      `json:"version"`
+		Creator harCreator  `json:"creator"`
+		Entries []*harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+// A harCreator is the app that made a HAR file.
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// A harEntry is a request and its response in HAR.
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// A harRequest is a request in HAR.
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// A harResponse is a response in HAR.
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// A harNameValue is a header, a query or a parameter in HAR.
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// A harCookie is a cookie in HAR.
+type harCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+// A harPostData is the body of a request in HAR.
+type harPostData struct {
+	MimeType string         `json:"mimeType"`
+	Params   []harNameValue `json:"params"`
+	Text     string         `json:"text"`
+}
+
+// A harContent is the body of a response in HAR.
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// A harTimings is the time in milliseconds of each phase of a request in
+// HAR, -1 means it is unknown.
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// A harTransport is a http.RoundTripper that save every request and its
+// response to a HAR file by save. Cookies, credentials and passwords are
+// replaced by HARRedacted, and only the start of text bodies is saved.
+type harTransport struct {
+	Transport http.RoundTripper
+	
+	entries []*harEntry
+	mutex   sync.Mutex
+}
+
+// getMilliseconds get milliseconds of a duration for HAR.
+func getMilliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
+
+// isTextMimeType check the body of the MIME type is text or not.
+func isTextMimeType(mimeType string) bool {
+	mimeType, _, _ = mime.ParseMediaType(mimeType)
+	return strings.HasPrefix(mimeType, "text/") ||
+			strings.HasSuffix(mimeType, "json") ||
+			strings.HasSuffix(mimeType, "xml") ||
+			strings.HasSuffix(mimeType, "javascript") ||
+			mimeType == "application/x-www-form-urlencoded"
+}
+
+// getHARNameValues get names and values sorted by names in HAR, values of
+// names that isRedacted return true are redacted.
+func getHARNameValues(values map[string][]string,
+		isRedacted func(name string) bool) (nameValues []harNameValue) {
+	var names = make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	nameValues = []harNameValue{}
+	for _, name := range names {
+		for _, value := range values[name] {
+			if isRedacted(name) {
+				value = HARRedacted
+			}
+			nameValues = append(nameValues, harNameValue{name, value})
+		}
+	}
+	return nameValues
+}
+
+// isRedactedHeader check the value of header should be redacted or not.
+func isRedactedHeader(name string) bool {
+	return harRedactedHeaders[http.CanonicalHeaderKey(name)]
+}
+
+// isRedactedParam check the value of query or form should be redacted
+// or not.
+func isRedactedParam(name string) bool {
+	return strings.Contains(strings.ToLower(name), "password")
+}
+
+// getHARCookies get cookies in HAR with values redacted.
+func getHARCookies(cookies []*http.Cookie) (harCookies []harCookie) {
+	harCookies = []harCookie{}
+	for _, cookie := range cookies {
+		var item = harCookie{
+			Name:     cookie.Name,
+			Value:    HARRedacted,
+			Path:     cookie.Path,
+			Domain:   cookie.Domain,
+			HTTPOnly: cookie.HttpOnly,
+			Secure:   cookie.Secure,
+		}
+		if !cookie.Expires.IsZero() {
+			item.Expires = cookie.Expires.Format(time.RFC3339)
+		}
+		harCookies = append(harCookies, item)
+	}
+	return harCookies
+}
+
+// getHARRequest get the request in HAR, the body is read and replaced so it
+// can be sent again.
+func getHARRequest(req *http.Request) (harReq harRequest, err error) {
+	var body []byte
+	harReq = harRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     getHARCookies(req.Cookies()),
+		Headers:     getHARNameValues(req.Header, isRedactedHeader),
+		QueryString: getHARNameValues(req.URL.Query(), isRedactedParam),
+		HeadersSize: -1,
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return harReq, nil
+	}
+	
+	if body, err = ioutil.ReadAll(req.Body); err != nil {
+		return harReq, err
+	}
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	harReq.BodySize = int64(len(body))
+	harReq.PostData = &harPostData{
+		MimeType: req.Header.Get("Content-Type"),
+		Params:   []harNameValue{},
+	}
+	
+	// Passwords in forms are redacted, so the text is made from the form.
+	var mimeType, _, _ = mime.ParseMediaType(harReq.PostData.MimeType)
+	if mimeType == "application/x-www-form-urlencoded" {
+		var form url.Values
+		if form, err = url.ParseQuery(string(body)); err != nil {
+			return harReq, err
+		}
+		harReq.PostData.Params = getHARNameValues(form, isRedactedParam)
+		for name := range form {
+			if isRedactedParam(name) {
+				form.Set(name, HARRedacted)
+			}
+		}
+		harReq.PostData.Text = form.Encode()
+	} else if isTextMimeType(harReq.PostData.MimeType) {
+		if len(body) > HARBodyLimit {
+			body = body[:HARBodyLimit]
+		}
+		harReq.PostData.Text = string(body)
+	}
+	return harReq, nil
+}
+
+// getHARResponse get the response in HAR without the body.
+func getHARResponse(resp *http.Response) harResponse {
+	var statusText = resp.Status
+	if i := strings.Index(statusText, " "); i >= 0 {
+		statusText = statusText[i+1:]
+	}
+	return harResponse{
+		Status:      resp.StatusCode,
+		StatusText:  statusText,
+		HTTPVersion: resp.Proto,
+		Cookies:     getHARCookies(resp.Cookies()),
+		Headers:     getHARNameValues(resp.Header, isRedactedHeader),
+		Content:     harContent{MimeType: resp.Header.Get("Content-Type")},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+}
+
+// RoundTrip is needed when implement a http.RoundTripper interface.
+func (ht *harTransport) RoundTrip(req *http.Request) (
+		resp *http.Response, err error) {
+	var (
+		start = time.Now()
+		entry = &harEntry{
+			StartedDateTime: start.Format(time.RFC3339Nano),
+			Timings:         harTimings{Blocked: -1, DNS: -1, Connect: -1},
+		}
+	)
+	if entry.Request, err = getHARRequest(req); err != nil {
+		return nil, err
+	}
+	ht.mutex.Lock()
+	ht.entries = append(ht.entries, entry)
+	ht.mutex.Unlock()
+	
+	resp, err = ht.Transport.RoundTrip(req)
+	
+	ht.mutex.Lock()
+	defer ht.mutex.Unlock()
+	entry.Timings.Wait = getMilliseconds(time.Since(start))
+	entry.Time = entry.Timings.Wait
+	if err != nil {
+		entry.Response = harResponse{Cookies: []harCookie{},
+			Headers: []harNameValue{}, HeadersSize: -1, BodySize: -1}
+		entry.Comment = err.Error()
+		return nil, err
+	}
+	entry.Response = getHARResponse(resp)
+	resp.Body = &harBody{
+		ReadCloser: resp.Body,
+		transport:  ht,
+		entry:      entry,
+		start:      time.Now(),
+		isText:     isTextMimeType(entry.Response.Content.MimeType),
+	}
+	return resp, nil
+}
+
+// A harBody is the body of response that save its size, the start of it
+// and the time of receiving it to an entry of HAR.
+type harBody struct {
+	io.ReadCloser
+	transport *harTransport
+	entry     *harEntry
+	start     time.Time
+	isText    bool
+	size      int64
+	text      bytes.Buffer
+	once      sync.Once
+}
+
+// Read read from the body and save the start of it if it is text.
+func (hb *harBody) Read(p []byte) (n int, err error) {
+	n, err = hb.ReadCloser.Read(p)
+	hb.size += int64(n)
+	if rest := HARBodyLimit - hb.text.Len(); hb.isText && rest > 0 {
+		if rest > n {
+			rest = n
+		}
+		hb.text.Write(p[:rest])
+	}
+	if err == io.EOF {
+		hb.done()
+	}
+	return n, err
+}
+
+// Close close the body, the entry is done even if the body is not read.
+func (hb *harBody) Close() error {
+	hb.done()
+	return hb.ReadCloser.Close()
+}
+
+// done save the body to the entry of HAR once.
+func (hb *harBody) done() {
+	hb.once.Do(func() {
+		hb.transport.mutex.Lock()
+		defer hb.transport.mutex.Unlock()
+		var content = &hb.entry.Response.Content
+		content.Size = hb.size
+		content.Text = hb.text.String()
+		if !hb.isText && hb.size > 0 {
+			content.Comment = "body is not text and not saved"
+		} else if hb.size > int64(hb.text.Len()) {
+			content.Comment = "body is truncated"
+		}
+		hb.entry.Response.BodySize = hb.size
+		hb.entry.Timings.Receive = getMilliseconds(time.Since(hb.start))
+		hb.entry.Time = hb.entry.Timings.Wait + hb.entry.Timings.Receive
+	})
+}
+
+// save save all entries to a HAR file.
+func (ht *harTransport) save(filename string) error {
+	var (
+		har  harLog
+		data []byte
+		err  error
+	)
+	ht.mutex.Lock()
+	har.Log.Version = "1.2"
+	har.Log.Creator = harCreator{Name: AppName, Version: "0"}
+	har.Log.Entries = append([]*harEntry{}, ht.entries...)
+	data, err = json.MarshalIndent(&har, "", "  ")
+	ht.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0600)
+}
@@ -0,0 +1,54 @@
+package pixiv
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHARTransport check requests of logging in are saved to a HAR file
+// without the password and the session.
+func TestHARTransport(t *testing.T) {
+	var (
+		fp       = newFakePixiv(t)
+		session  = NewSession(fp.client(false))
+		ht       = &harTransport{Transport: session.Client.Transport}
+		filename = filepath.Join(t.TempDir(), "session.har")
+		data     []byte
+		har      harLog
+		err      error
+	)
+	session.Client.Transport = ht
+	if err = session.Login(context.Background(),
+		fakeUsername, fakePassword); err != nil {
+		t.Fatal(err)
+	}
+	if err = ht.save(filename); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = ioutil.ReadFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(data, &har); err != nil {
+		t.Fatal(err)
+	}
+	
+	if har.Log.Version != "1.2" {
+		t.Errorf("version is %q, not \"1.2\"", har.Log.Version)
+	}
+	if len(har.Log.Entries) != len(fp.getRequests(false)) {
+		t.Errorf("%d entries are saved for %d requests",
+			len(har.Log.Entries), len(fp.getRequests(false)))
+	}
+	for _, secret := range []string{fakePassword, fakeSession} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("%q is saved in the HAR file", secret)
+		}
+	}
+	if !strings.Contains(string(data), fakeUsername) {
+		t.Errorf("%q is not saved in the HAR file", fakeUsername)
+	}
+}
@@ -0,0 +1,247 @@
+package pixiv
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An htmlSource is a MetadataSource that get data from work pages of Pixiv
+// with the old layout, Pages is the max number of manga pages that are
+// gotten at the same time.
+type htmlSource struct {
+	Client *Client
+	Pages  int
+}
+
+// FetchWork get data of work and artist from the work page.
+func (s *htmlSource) FetchWork(ctx context.Context, id string) (
+		artistData *ArtistData, workData *WorkData, err error) {
+	var (
+		resp *http.Response
+		body string
+		m    = &matcher{Prefix: HTMLSource, WorkID: id}
+	)
+	
+	// Get response body of the work.
+	if resp, err = s.Client.GetContext(ctx,
+		fmt.Sprintf(PixivWorkURL, id)); err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, throw(HTMLSource,
+			"request status is not OK when getting work page")
+	}
+	if body, err = getResponseBody(resp); err != nil {
+		return nil, nil, err
+	}
+	
+	// Get data of work and artist.
+	artistData, workData = new(ArtistData), &WorkData{ID: id}
+	if err = s.getArtistData(m, body, artistData); err != nil {
+		return nil, nil, err
+	}
+	if err = s.getWorkData(ctx, m, body, workData); err != nil {
+		return nil, nil, err
+	}
+	return artistData, workData, nil
+}
+
+// getArtistData get artist data from response body of a work.
+func (s *htmlSource) getArtistData(m *matcher, body string,
+		artistData *ArtistData) (err error) {
+	// get artist ID, username, nickname, username is optional.
+	if artistData.ID, err = m.match("artist.id", body,
+		`href="/member.php\?id=(\d+?)" class="tab-profile"`); err != nil {
+		return err
+	}
+	artistData.Username = m.matchOptional(body,
+		`href="/stacc/(.+?)" class="tab-feed"`)
+	if artistData.Nickname, err = m.match("artist.nickname", body,
+		`<span class="user-name">(.+?)</span>`); err != nil {
+		return err
+	}
+	return nil
+}
+
+// getWorkData get work data from response body of a work. Series, caption,
+// tools, tags, type and thumbnail are optional, they are empty or default
+// if they are not found.
+func (s *htmlSource) getWorkData(ctx context.Context, m *matcher,
+		body string, workData *WorkData) (err error) {
+	var (
+		meta, tags, workType, thumbURL, pageMeta string
+		metaMatch, tagsMatch                     [][]string
+	)
+	
+	// Get work name.
+	if workData.Name, err = m.match("work.name", body,
+		`<h1 class="title">(.+?)</h1>`); err != nil {
+		return err
+	}
+	
+	// Get work meta that include time, page count or width / height, and tools.
+	const metaPattern = `<li>(<ul class="tools">(.+?)</ul>)?(.+?)?</li>`
+	if meta, err = m.match("work.meta", body, `<ul class="meta">(.+?)</ul>`+
+			`(<div class="_illust-series-title">(.+?)</div>)?`+
+			`<h1 class="title">`); err != nil {
+		return err
+	}
+	if metaMatch = m.matchAll(meta, metaPattern); len(metaMatch) < 2 {
+		return m.throw("work.meta", meta,
+			regexp.MustCompile(metaPattern), nil)
+	}
+	
+	// Get time from meta.
+	const timeLayout = "2006年1月2日 15:04 MST"
+	if workData.Time, err = time.Parse(timeLayout,
+		metaMatch[0][3]+" JST"); err != nil {
+		return &ParseError{Prefix: m.Prefix, Field: "work.time",
+			Pattern: timeLayout, WorkID: m.WorkID,
+			Snippet: getSnippet(metaMatch[0][3], nil), Err: err}
+	}
+	
+	// Get page count or width / height from meta.
+	pageMeta = metaMatch[1][3]
+	if strings.Index(pageMeta, "×") >= 0 {
+		// When width / height case, page count is 1.
+		var size = strings.Split(pageMeta, "×")
+		workData.PageCount = 1
+		workData.Pages = make([]PageData, workData.PageCount)
+		if workData.Pages[0].Width, err = strconv.ParseUint(
+			size[0], 10, 64); err != nil {
+			return m.throw("width", pageMeta, nil, err)
+		}
+		if workData.Pages[0].Height, err = strconv.ParseUint(
+			size[1], 10, 64); err != nil {
+			return m.throw("height", pageMeta, nil, err)
+		}
+	} else {
+		// When page count case, width / height can only get from file.
+		var pageCount string
+		if pageCount, err = m.match("work.page_count",
+			pageMeta, `^.* (\d+)P$`); err != nil {
+			return err
+		}
+		if workData.PageCount, err = strconv.ParseUint(
+			pageCount, 10, 64); err != nil {
+			return m.throw("work.page_count", pageMeta, nil, err)
+		}
+		workData.Pages = make([]PageData, workData.PageCount)
+	}
+	
+	// Get tools from meta.
+	if len(metaMatch) == 3 {
+		var toolsMatch = m.matchAll(metaMatch[2][2], `<li>(.+?)</li>`)
+		workData.Tools = make([]string, len(toolsMatch))
+		for i, tool := range toolsMatch {
+			workData.Tools[i] = tool[1]
+		}
+	}
+	
+	// Get work series and caption.
+	workData.Series = m.matchOptional(body,
+		`<a class="_illust-series-title-text" href=".+?">(.+?)</a>`)
+	workData.Caption = m.matchOptional(body, `<p class="caption">(.+?)</p>`)
+	
+	// Get work tags.
+	tags = m.matchOptional(body, `<span class="tags-container">`+
+			`(.+?)</span><script id="template-work-tags"`)
+	tagsMatch = m.matchAll(tags, `class="text">(.+?)</a>`)
+	workData.Tags = make([]string, len(tagsMatch))
+	for i, tag := range tagsMatch {
+		workData.Tags[i] = tag[1]
+	}
+	
+	// Get work type.
+	workType = m.matchOptional(body,
+		`class="(.+?)"><div class="_layout-thumbnail">`)
+	if strings.Index(workType, "ugoku-illust") >= 0 {
+		workData.Type = Ugoira
+	} else if strings.Index(workType, "manga") >= 0 {
+		workData.Type = Manga
+	} else {
+		workData.Type = Illust
+	}
+	
+	// Get work thumbnail in base64 form.
+	if thumbURL = m.matchOptional(body,
+		`class="bookmark_modal_thumbnail" data-src="(.+?)"`); thumbURL != "" {
+		if workData.Thumb, err = s.getThumb(ctx, thumbURL); err != nil {
+			return err
+		}
+	}
+	
+	// Get URL and filename of each image of work.
+	if workData.PageCount == 1 {
+		workData.Pages[0].Page = 0
+		if workData.Type != Ugoira {
+			if workData.Pages[0].ImageURL, err = m.match("url", body,
+				`data-src="(.+?)" class="original-image"`); err != nil {
+				return err
+			}
+		}
+		workData.Pages[0].Filename = path.Base(workData.Pages[0].ImageURL)
+	} else if workData.PageCount > 1 {
+		if err = runJobs(ctx, int(workData.PageCount), s.Pages,
+			func(i int) (err error) {
+				var (
+					resp *http.Response
+					body string
+				)
+				workData.Pages[i].Page = uint64(i)
+				if resp, err = s.Client.GetContext(ctx, fmt.Sprintf(
+					PixivMangaURL, workData.ID, i)); err != nil {
+					return err
+				}
+				defer resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					return throw(HTMLSource,
+						"request status is not OK when getting manga page")
+				}
+				if body, err = getResponseBody(resp); err != nil {
+					return err
+				}
+				if workData.Pages[i].ImageURL, err = m.match(
+					"url", body, `src="(.+?)"`); err != nil {
+					return err
+				}
+				workData.Pages[i].Filename =
+						path.Base(workData.Pages[i].ImageURL)
+				return nil
+			}, nil); err != nil {
+			return err
+		}
+	}
+	
+	return nil
+}
+
+// getThumb get the thumbnail of work from URL in base64 form.
+func (s *htmlSource) getThumb(ctx context.Context,
+		url string) (_ string, err error) {
+	var (
+		resp      *http.Response
+		bodyBytes []byte
+	)
+	if resp, err = s.Client.GetContext(ctx, url); err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", throw(HTMLSource,
+			"request status is not OK when getting thumbnail")
+	}
+	if bodyBytes, err = ioutil.ReadAll(resp.Body); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(bodyBytes), nil
+}
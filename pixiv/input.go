@@ -0,0 +1,122 @@
+package pixiv
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// An InputKind resolve the kind of an input of download.
+type InputKind uint8
+
+const (
+	WorkInput InputKind = iota
+	UserInput
+	ListInput
+)
+
+// StdinInput is the input that read a list from stdin.
+const StdinInput = "-"
+
+// An Input is a resolved input of download, ID is a work ID, a user ID
+// or the filename of a list by its kind.
+type Input struct {
+	Kind InputKind
+	ID   string
+}
+
+var (
+	// Patterns of paths in www.pixiv.net like "/artworks/123",
+	// "/en/artworks/123", "/i/123", "/users/123" or "/en/users/123".
+	workPathRegexp = regexp.MustCompile(
+		`^/(?:[a-z]{2}/)?(?:artworks|i)/(\d+)/?$`)
+	userPathRegexp = regexp.MustCompile(
+		`^/(?:[a-z]{2}/)?users/(\d+)(?:/.*)?$`)
+	// Pattern of filenames in i.pximg.net like "123_p0.png",
+	// "123_p0_master1200.jpg" or "123_ugoira0.jpg".
+	imageNameRegexp = regexp.MustCompile(`^(\d+)_(?:p\d+|ugoira)`)
+)
+
+// resolveInput resolve an input that is a work ID, a URL of work, image or
+// user in Pixiv, the filename of a list, or StdinInput.
+func resolveInput(str string) (_ *Input, err error) {
+	var (
+		inputURL *url.URL
+		isExist  bool
+		throwErr = &AppError{Prefix: "input", Msg: "\"" + str +
+				"\" is not a work ID, a Pixiv URL or a list file"}
+	)
+	
+	switch {
+	case str == StdinInput:
+		return &Input{Kind: ListInput, ID: str}, nil
+	case isAllDigits(str):
+		return &Input{Kind: WorkInput, ID: str}, nil
+	}
+	
+	// A filename of list is checked before URL,
+	// because a URL without scheme looks like a filename.
+	if isExist, err = isFileExist(str); err != nil {
+		return nil, err
+	} else if isExist {
+		return &Input{Kind: ListInput, ID: str}, nil
+	}
+	
+	if !strings.Contains(str, "://") {
+		str = "https://" + str
+	}
+	if inputURL, err = url.Parse(str); err != nil {
+		return nil, throwErr
+	}
+	
+	switch host := strings.ToLower(inputURL.Hostname()); {
+	case host == "pixiv.net" || host == "www.pixiv.net":
+		var query = inputURL.Query()
+		if match := workPathRegexp.FindStringSubmatch(
+			inputURL.Path); match != nil {
+			return &Input{Kind: WorkInput, ID: match[1]}, nil
+		}
+		if match := userPathRegexp.FindStringSubmatch(
+			inputURL.Path); match != nil {
+			return &Input{Kind: UserInput, ID: match[1]}, nil
+		}
+		
+		// Old URLs like "member_illust.php?mode=medium&illust_id=123",
+		// "member_illust.php?id=123" or "member.php?id=123".
+		switch path.Base(inputURL.Path) {
+		case "member_illust.php":
+			if id := query.Get("illust_id"); isAllDigits(id) {
+				return &Input{Kind: WorkInput, ID: id}, nil
+			}
+			fallthrough
+		case "member.php":
+			if id := query.Get("id"); isAllDigits(id) {
+				return &Input{Kind: UserInput, ID: id}, nil
+			}
+		}
+	case host == "pximg.net" || strings.HasSuffix(host, ".pximg.net"):
+		if match := imageNameRegexp.FindStringSubmatch(
+			path.Base(inputURL.Path)); match != nil {
+			return &Input{Kind: WorkInput, ID: match[1]}, nil
+		}
+	}
+	
+	return nil, throwErr
+}
+
+// resolveInputs resolve inputs, all inputs are resolved before downloading
+// so an invalid input is found early.
+func resolveInputs(strs []string) (inputs []*Input, err error) {
+	for _, str := range strs {
+		var input *Input
+		if input, err = resolveInput(str); err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, input)
+	}
+	if len(inputs) == 0 {
+		return nil, &AppError{Prefix: "input", Msg: "no input"}
+	}
+	return inputs, nil
+}
@@ -0,0 +1,94 @@
+package pixiv
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestResolveInput check each accepted form of input is resolved to a work
+// ID, a user ID or a list, and other inputs are rejected.
+func TestResolveInput(t *testing.T) {
+	var list = filepath.Join(t.TempDir(), "list.jsonl")
+	if err := ioutil.WriteFile(list, []byte(`{"id":"123"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name  string
+		input string
+		want  *Input
+	}{
+		{"ID", "123", &Input{WorkInput, "123"}},
+		{"Stdin", StdinInput, &Input{ListInput, StdinInput}},
+		{"List", list, &Input{ListInput, list}},
+		{"Artworks", "https://www.pixiv.net/artworks/123",
+			&Input{WorkInput, "123"}},
+		{"ArtworksLang", "https://www.pixiv.net/en/artworks/123/",
+			&Input{WorkInput, "123"}},
+		{"ShortWork", "www.pixiv.net/i/123", &Input{WorkInput, "123"}},
+		{"NoSubdomain", "http://pixiv.net/artworks/123",
+			&Input{WorkInput, "123"}},
+		{"Users", "https://www.pixiv.net/users/45",
+			&Input{UserInput, "45"}},
+		{"UsersLang", "https://www.pixiv.net/en/users/45/illustrations",
+			&Input{UserInput, "45"}},
+		{"OldWork", "https://www.pixiv.net/member_illust.php?" +
+				"mode=medium&illust_id=123", &Input{WorkInput, "123"}},
+		{"OldUserWorks", "https://www.pixiv.net/member_illust.php?id=45",
+			&Input{UserInput, "45"}},
+		{"OldUser", "https://www.pixiv.net/member.php?id=45",
+			&Input{UserInput, "45"}},
+		{"Image", "https://i.pximg.net/img-original/img/" +
+				"2018/01/01/00/00/00/123_p0.png", &Input{WorkInput, "123"}},
+		{"MasterImage", "https://i.pximg.net/c/250x250_80_a2/img-master/" +
+				"img/2018/01/01/00/00/00/123_p1_master1200.jpg",
+			&Input{WorkInput, "123"}},
+		{"Ugoira", "https://i.pximg.net/img-zip-ugoira/img/" +
+				"2018/01/01/00/00/00/123_ugoira1920x1080.zip",
+			&Input{WorkInput, "123"}},
+		{"NotExistList", "not_exist.jsonl", nil},
+		{"Home", "https://www.pixiv.net/", nil},
+		{"NotDigits", "https://www.pixiv.net/artworks/12a", nil},
+		{"OldWorkWithoutID", "https://www.pixiv.net/member_illust.php?" +
+				"mode=medium", nil},
+		{"OldUserNotDigits", "https://www.pixiv.net/member.php?id=abc", nil},
+		{"OtherHost", "https://example.com/artworks/123", nil},
+		{"OtherImage", "https://i.pximg.net/common/images/no_profile.png",
+			nil},
+		{"BadURL", "https://www.pixiv.net/%zz", nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var input, err = resolveInput(test.input)
+			if test.want == nil {
+				if err == nil {
+					t.Errorf("%q is resolved to %+v, not rejected",
+						test.input, input)
+				}
+			} else if err != nil {
+				t.Errorf("%q is rejected: %v", test.input, err)
+			} else if !reflect.DeepEqual(input, test.want) {
+				t.Errorf("%q is resolved to %+v, not %+v", test.input,
+					input, test.want)
+			}
+		})
+	}
+}
+
+// TestResolveInputs check inputs are resolved in order, and no input or
+// an invalid input is rejected.
+func TestResolveInputs(t *testing.T) {
+	var inputs, err = resolveInputs([]string{
+		"https://www.pixiv.net/users/45", "123"})
+	if err != nil {
+		t.Fatal(err)
+	} else if want := []*Input{{UserInput, "45"},
+		{WorkInput, "123"}}; !reflect.DeepEqual(inputs, want) {
+		t.Errorf("inputs are resolved to %+v, not %+v", inputs, want)
+	}
+	for _, strs := range [][]string{nil, {"123", "https://example.com/"}} {
+		if inputs, err = resolveInputs(strs); err == nil {
+			t.Errorf("%q is resolved to %+v, not rejected", strs, inputs)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package pixiv
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A listItem save the data of a work in a list file or from other inputs,
+// and the selection of its pages. A list file has
+// a work in each line, it can be written by hand or generated by other
+// commands. Each line is one of:
+//
+//     # A comment, empty lines are ignored too.
+//     12345678
+//     12345678 0-2,5  # The work ID and the selection of pages.
+//     https://www.pixiv.net/artworks/12345678 3
+//     https://www.pixiv.net/users/12345  # All works of a user.
+//     {"work": {"id": "12345678"}, "select": "3-"}
+//     {"artist": {"id": "1", "nickname": "A"}, "work": {"id": "2",
+//         "name": "B", "type": "manga", "pages": [{"page": 0, "url": "..."},
+//         {"page": 1, "url": "..."}]}}
+//
+// A work can be given by its ID or a URL of work or image that is resolved
+// like inputs of download, but a list file can not be given in a list.
+// A line in JSON have fields of ArtistData and WorkData that named as the
+// tag "json" of them. The work page is not fetched when all pages have URL
+// and all fields used by naming patterns are given or not empty, otherwise
+// only fields that are not given and empty are set from the work page, so
+// a given field like "type": "illust" is kept. "type" should be set if
+// naming patterns of work types are used. A page without "page" is
+// numbered by its index in "pages", and pages can not have the same number.
+//
+// A selection of pages is a list of page numbers split by ",", each of it
+// is a page "N", a range "N-M" or all pages after a page "N-". The first
+// page of a work is 0.
+type listItem struct {
+	Artist    *ArtistData `json:"artist,omitempty"`
+	Work      *WorkData   `json:"work"`
+	Selection string      `json:"select,omitempty"`
+	
+	selection pageSelection
+	userID    string
+	// given save keys of fields given by a line in JSON, each key is the
+	// first name in the tag "tag" of the field like "work.type".
+	given map[string]bool
+}
+
+// A pageSelection save ranges of selected pages, it selects all pages
+// if it is nil.
+type pageSelection [][2]uint64
+
+// parsePageSelection parse a selection of pages like "0-2,5,7-".
+func parsePageSelection(str string) (selection pageSelection, err error) {
+	if strings.TrimSpace(str) == "" {
+		return nil, nil
+	}
+	for _, item := range strings.Split(str, ",") {
+		var (
+			bounds    = strings.SplitN(strings.TrimSpace(item), "-", 2)
+			pageRange [2]uint64
+		)
+		if pageRange[0], err = strconv.ParseUint(
+			bounds[0], 10, 64); err != nil {
+			return nil, errors.New(
+				"page selection \"" + str + "\" is invalid")
+		}
+		pageRange[1] = pageRange[0]
+		if len(bounds) == 2 && bounds[1] == "" {
+			pageRange[1] = math.MaxUint64
+		} else if len(bounds) == 2 {
+			if pageRange[1], err = strconv.ParseUint(bounds[1], 10,
+				64); err != nil || pageRange[1] < pageRange[0] {
+				return nil, errors.New(
+				"page selection \"" + str + "\" is invalid")
+			}
+		}
+		selection = append(selection, pageRange)
+	}
+	return selection, nil
+}
+
+// has check the page is selected or not.
+func (ps pageSelection) has(page uint64) bool {
+	if ps == nil {
+		return true
+	}
+	for _, pageRange := range ps {
+		if page >= pageRange[0] && page <= pageRange[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// parseListLine parse a line of list file, it return nil if the line is
+// empty or a comment.
+func parseListLine(line string) (item *listItem, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+	
+	if strings.HasPrefix(line, "{") {
+		// A line in JSON.
+		if err = json.Unmarshal([]byte(line), &item); err != nil {
+			return nil, err
+		}
+		if item.Work == nil || item.Work.ID == "" {
+			return nil, errors.New("work ID is required")
+		}
+		if item.Artist == nil {
+			item.Artist = new(ArtistData)
+		}
+		if err = setPageNumbers(line, item.Work); err != nil {
+			return nil, err
+		}
+		if item.given, err = getGivenFields(line); err != nil {
+			return nil, err
+		}
+	} else {
+		// A line with the work or the user and the selection of pages.
+		var input *Input
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		var fields = strings.Fields(line)
+		if input, err = resolveInput(fields[0]); err != nil {
+			return nil, err
+		}
+		item = &listItem{
+			Artist:    new(ArtistData),
+			Work:      new(WorkData),
+			Selection: strings.Join(fields[1:], ""),
+		}
+		switch input.Kind {
+		case WorkInput:
+			item.Work.ID = input.ID
+		case UserInput:
+			if item.Selection != "" {
+				return nil, errors.New("pages of a user can not be selected")
+			}
+			item.userID = input.ID
+		default:
+			return nil, errors.New("a list can not be given in a list")
+		}
+	}
+	
+	if item.selection, err = parsePageSelection(item.Selection); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// setPageNumbers set the number of pages in workData that do not have
+// "page" in the line in JSON to their index, and check no pages have the
+// same number.
+func setPageNumbers(line string, workData *WorkData) (err error) {
+	var (
+		data struct {
+			Work struct {
+				Pages []struct {
+					Page *uint64 `json:"page"`
+				} `json:"pages"`
+			} `json:"work"`
+		}
+		pages = make(map[uint64]bool)
+	)
+	if err = json.Unmarshal([]byte(line), &data); err != nil {
+		return err
+	}
+	for i, page := range data.Work.Pages {
+		if page.Page == nil {
+			workData.Pages[i].Page = uint64(i)
+		}
+		if pages[workData.Pages[i].Page] {
+			return errors.New("more than one page " +
+					strconv.FormatUint(workData.Pages[i].Page, 10))
+		}
+		pages[workData.Pages[i].Page] = true
+	}
+	return nil
+}
+
+// getGivenFields get keys of fields of ArtistData and WorkData that are
+// given by the line in JSON, fields given as null are not counted.
+func getGivenFields(line string) (given map[string]bool, err error) {
+	var data struct {
+		Artist map[string]json.RawMessage `json:"artist"`
+		Work   map[string]json.RawMessage `json:"work"`
+	}
+	if err = json.Unmarshal([]byte(line), &data); err != nil {
+		return nil, err
+	}
+	given = make(map[string]bool)
+	for types, values := range map[reflect.Type]map[string]json.RawMessage{
+		reflect.TypeOf(ArtistData{}): data.Artist,
+		reflect.TypeOf(WorkData{}):   data.Work,
+	} {
+		for i := 0; i < types.NumField(); i++ {
+			var name = strings.Split(types.Field(i).Tag.Get("json"), ",")[0]
+			if value, isGiven := values[name]; isGiven &&
+					string(value) != "null" {
+				given[getFieldKey(types, i)] = true
+			}
+		}
+	}
+	return given, nil
+}
+
+// getFieldKey get the key of the field at index of data type in
+// listItem.given.
+func getFieldKey(types reflect.Type, index int) string {
+	return strings.Split(types.Field(index).Tag.Get("tag"), ",")[0]
+}
+
+// readList read all works from a list named name, the line number is
+// added to the error if a line is invalid.
+func readList(reader io.Reader, name string) (items []*listItem, err error) {
+	// A line in JSON may be longer than the default max size of token.
+	var scanner = bufio.NewScanner(reader)
+	scanner.Buffer(nil, 16*1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		var item *listItem
+		if item, err = parseListLine(scanner.Text()); err != nil {
+			return nil, &AppError{Prefix: "list", Msg: "line " +
+					strconv.Itoa(lineNum) + " of \"" + name +
+					"\": " + err.Error()}
+		}
+		if item != nil {
+			items = append(items, item)
+		}
+	}
+	return items, scanner.Err()
+}
+
+// isAllDigits check str only have digits or not.
+func isAllDigits(str string) bool {
+	for _, c := range str {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return str != ""
+}
+
+// fillEmptyFields set fields of dst that are not given and empty from
+// src, both of them must be pointers to the same type of struct.
+func fillEmptyFields(dst, src interface{}, given map[string]bool) {
+	var (
+		dstVal = reflect.ValueOf(dst).Elem()
+		srcVal = reflect.ValueOf(src).Elem()
+	)
+	for i := 0; i < dstVal.NumField(); i++ {
+		var field = dstVal.Field(i)
+		if field.CanSet() && !given[getFieldKey(dstVal.Type(), i)] &&
+				reflect.DeepEqual(field.Interface(),
+					reflect.Zero(field.Type()).Interface()) {
+			field.Set(srcVal.Field(i))
+		}
+	}
+}
+
+// getItemsFromList get works from given list file, or from stdin if
+// filename is StdinInput. Users in list are replaced by their works.
+func (d *Downloader) getItemsFromList(ctx context.Context,
+		filename string) (items []*listItem, err error) {
+	var (
+		listItems []*listItem
+		file      = os.Stdin
+	)
+	if filename != StdinInput {
+		if file, err = os.Open(filename); err != nil {
+			return nil, err
+		}
+		defer file.Close()
+	}
+	if listItems, err = readList(file, filename); err != nil {
+		return nil, err
+	}
+	
+	for _, item := range listItems {
+		var userItems []*listItem
+		if item.userID == "" {
+			items = append(items, item)
+			continue
+		}
+		if userItems, err = d.getItemsFromUser(ctx, item.userID); err != nil {
+			return nil, err
+		}
+		items = append(items, userItems...)
+	}
+	return items, nil
+}
+
+// isWorkFilled check all pages have URL and all fields used by naming
+// patterns are given or not empty, so the work page does not need to be
+// fetched.
+func (d *Downloader) isWorkFilled(item *listItem) bool {
+	if len(item.Work.Pages) == 0 {
+		return false
+	}
+	for _, page := range item.Work.Pages {
+		if page.ImageURL == "" {
+			return false
+		}
+	}
+	return d.Naming.getRule(item.Work.Type).isFilled(item.Artist, item.Work,
+		item.given)
+}
@@ -0,0 +1,102 @@
+package pixiv
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	
+	"github.com/abc1236762/pixiv_tool/internal/golden"
+)
+
+func TestParseListLine(t *testing.T) {
+	for _, test := range []struct {
+		line   string
+		id     string
+		pages  []uint64
+		errMsg string
+	}{
+		{"# comment", "", nil, ""},
+		{"12345678 0-2,5", "12345678", nil, ""},
+		{`{"work": {"id": "1", "pages": [{"url": "a"}, {"url": "b"}]}}`,
+			"1", []uint64{0, 1}, ""},
+		{`{"work": {"id": "1", "pages": [{"page": 3, "url": "a"},` +
+				` {"page": 1, "url": "b"}]}}`, "1", []uint64{3, 1}, ""},
+		{`{"work": {"id": "1", "pages": [{"url": "a"}, {"url": "b"},` +
+				` {"page": 5, "url": "c"}]}}`, "1", []uint64{0, 1, 5}, ""},
+		{`{"work": {"id": "1", "pages": [{"page": 1, "url": "a"},` +
+				` {"url": "b"}]}}`, "", nil, "more than one page 1"},
+		{`{"work": {"id": "1", "pages": [{"page": 0, "url": "a"},` +
+				` {"page": 0, "url": "b"}]}}`, "", nil, "more than one page 0"},
+		{`{"work": {}}`, "", nil, "work ID is required"},
+	} {
+		var item, err = parseListLine(test.line)
+		if test.errMsg != "" {
+			if err == nil || !strings.Contains(err.Error(), test.errMsg) {
+				t.Errorf("error %v of %q does not contain %q",
+					err, test.line, test.errMsg)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("unexpected error of %q: %v", test.line, err)
+			continue
+		} else if item == nil {
+			if test.id != "" {
+				t.Errorf("%q is ignored", test.line)
+			}
+			continue
+		}
+		var pages []uint64
+		for _, page := range item.Work.Pages {
+			pages = append(pages, page.Page)
+		}
+		if item.Work.ID != test.id || !reflect.DeepEqual(pages, test.pages) {
+			t.Errorf("work of %q is %q with pages %v, not %q with pages %v",
+				test.line, item.Work.ID, pages, test.id, test.pages)
+		}
+	}
+}
+
+// TestDownloaderDownloadList check the work page is not fetched for a
+// line in JSON that give all fields used by naming patterns, and given
+// fields like "type": "illust" are not replaced by fetched data.
+func TestDownloaderDownloadList(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		line string
+	}{
+		{"Filled", `{"artist": {"nickname": "A"}, "work": {"id": "100", ` +
+				`"name": "W", "type": "illust", "pages": [{"url": "` +
+				testImageURL + `"}]}}`},
+		{"TypeKept", `{"work": {"id": "200", "type": "illust"}}`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				fp         = newFakePixiv(t)
+				options    = DefaultDownloadOptions()
+				list       = filepath.Join(t.TempDir(), "list.jsonl")
+				downloader *Downloader
+				err        error
+				g          golden.File
+			)
+			options.Path = t.TempDir()
+			options.Naming.SingleFile = "<work.type>/<artist.name>/<work.name>"
+			options.Naming.Folder = "<work.type>/<artist.name>/<work.name>"
+			if downloader, err = NewDownloader(fp.client(true),
+				options); err != nil {
+				t.Fatal(err)
+			}
+			if err = ioutil.WriteFile(list, []byte(test.line+"\n"),
+				0644); err != nil {
+				t.Fatal(err)
+			}
+			
+			g.Result(downloader.Download(context.Background(), list))
+			g.Section("requests", fp.getRequests(true)...)
+			g.Section("files", getTree(t, downloader.Path)...)
+			g.Check(t)
+		})
+	}
+}
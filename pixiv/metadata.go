@@ -0,0 +1,95 @@
+package pixiv
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// AJAXSource get data from AJAX API of Pixiv.
+	AJAXSource = "ajax"
+	// HTMLSource get data from work pages of Pixiv, it only works with
+	// the old layout of Pixiv.
+	HTMLSource = "html"
+	// FixtureSource get data from files named like "12345678.json" in
+	// DownloadOptions.FixtureDir without network, each file is a line of
+	// list in JSON.
+	FixtureSource = "fixture"
+)
+
+// A MetadataSource get data of works and artists, so the way to get data
+// can be changed without changing how works are downloaded.
+type MetadataSource interface {
+	// FetchWork get data of a work and its artist by the work ID.
+	FetchWork(ctx context.Context, id string) (*ArtistData, *WorkData,
+		error)
+}
+
+// FetchWork get data of a work and its artist by the work ID from AJAX API
+// of Pixiv.
+func (c *Client) FetchWork(ctx context.Context,
+		id string) (*ArtistData, *WorkData, error) {
+	return (&ajaxSource{Client: c}).FetchWork(ctx, id)
+}
+
+// checkMetadataSource check the name of MetadataSource is valid or not.
+func checkMetadataSource(source string) error {
+	switch source {
+	case AJAXSource, HTMLSource, FixtureSource:
+		return nil
+	}
+	return &AppError{
+		Prefix: "metadata",
+		Msg: "source \"" + source + "\" is not \"" + AJAXSource +
+				"\", \"" + HTMLSource + "\" or \"" + FixtureSource + "\"",
+	}
+}
+
+// newMetadataSource make the MetadataSource named DownloadOptions.Source.
+func (d *Downloader) newMetadataSource() MetadataSource {
+	switch d.Source {
+	case HTMLSource:
+		return &htmlSource{Client: d.Client, Pages: d.Pages}
+	case FixtureSource:
+		return &fixtureSource{Dir: d.FixtureDir}
+	default:
+		return &ajaxSource{Client: d.Client}
+	}
+}
+
+// A fixtureSource is a MetadataSource that get data from files in Dir.
+type fixtureSource struct {
+	Dir string
+}
+
+// FetchWork get data of work and artist from the file named by the work
+// ID in fixtureSource.Dir.
+func (s *fixtureSource) FetchWork(_ context.Context,
+		id string) (_ *ArtistData, _ *WorkData, err error) {
+	var (
+		file *os.File
+		item listItem
+	)
+	if file, err = os.Open(filepath.Join(s.Dir,
+		id+".json")); os.IsNotExist(err) {
+		return nil, nil, throw(FixtureSource,
+			"data of work \""+ id+ "\" is not found in \""+ s.Dir+ "\"")
+	} else if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	if err = json.NewDecoder(file).Decode(&item); err != nil {
+		return nil, nil, throw(FixtureSource,
+			"data of work \""+ id+ "\" is invalid: "+ err.Error())
+	}
+	if item.Work == nil || item.Work.ID != id {
+		return nil, nil, throw(FixtureSource,
+			"data of work \""+ id+ "\" does not have the same ID")
+	}
+	if item.Artist == nil {
+		item.Artist = new(ArtistData)
+	}
+	return item.Artist, item.Work, nil
+}
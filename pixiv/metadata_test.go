@@ -0,0 +1,43 @@
+package pixiv
+
+import (
+	"context"
+	"testing"
+	
+	"github.com/abc1236762/pixiv_tool/internal/golden"
+)
+
+// TestFixtureSource check works are downloaded with data from files by
+// FixtureSource, and only images are requested.
+func TestFixtureSource(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		id   string
+	}{
+		{"Found", "100"},
+		{"NotFound", "300"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				fp         = newFakePixiv(t)
+				options    = DefaultDownloadOptions()
+				downloader *Downloader
+				item       = getItemFromID(test.id)
+				err        error
+				g          golden.File
+			)
+			options.Path = t.TempDir()
+			options.Source = FixtureSource
+			options.FixtureDir = "testdata/fixture"
+			if downloader, err = NewDownloader(fp.client(true),
+				options); err != nil {
+				t.Fatal(err)
+			}
+			
+			g.Result(downloader.download(context.Background(), item))
+			g.Section("requests", fp.getRequests(true)...)
+			g.Section("files", getTree(t, downloader.Path)...)
+			g.Check(t)
+		})
+	}
+}
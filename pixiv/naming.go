@@ -0,0 +1,405 @@
+package pixiv
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A namingField save the data of a field that can be put in naming pattern.
+type namingField struct {
+	Name   string
+	Owner  reflect.Type
+	Index  int
+	CanUse bool
+}
+
+// A namingPart is a part of naming pattern, it is a text or a field with
+// modifiers that format the value of field.
+type namingPart struct {
+	Text     string
+	Field    *namingField
+	Modifier namingModifier
+}
+
+// A namingModifier save modifiers of a field in naming pattern, like
+// "<work.time:2006-01-02>", "<work.page:03>", "<work.name:trunc=40>",
+// "<work.tags:join=,:first=3>" or "<work.series|default=no-series>". ":"
+// in a layout must be escaped like "<work.time:2006-01-02 15\:04>".
+type namingModifier struct {
+	Layout  string
+	Width   int
+	PadZero bool
+	Trunc   int
+	Join    string
+	First   int
+	Default string
+}
+
+// A namingPattern is a compiled naming pattern.
+type namingPattern struct {
+	Pattern string
+	Parts   []namingPart
+}
+
+// namingFields save all fields of data that have tag "tag", each name in
+// tag "tag" split by "," can be used as placeholder like "<work.id>".
+var namingFields = getNamingFields(ArtistData{}, WorkData{}, PageData{})
+
+// getNamingFields get fields of data from tag "tag" and "naming".
+func getNamingFields(data ...interface{}) map[string]*namingField {
+	var fields = make(map[string]*namingField)
+	for _, d := range data {
+		var types = reflect.TypeOf(d)
+		for i := 0; i < types.NumField(); i++ {
+			var field = types.Field(i)
+			if field.Tag.Get("tag") == "" {
+				continue
+			}
+			for _, name := range strings.Split(field.Tag.Get("tag"), ",") {
+				fields[name] = &namingField{
+					Name:   name,
+					Owner:  types,
+					Index:  i,
+					CanUse: field.Tag.Get("naming") != "-",
+				}
+			}
+		}
+	}
+	return fields
+}
+
+// throwNaming return an error about naming pattern.
+func throwNaming(pattern, msg string) error {
+	return &AppError{
+		Prefix: "naming",
+		Msg:    "pattern \"" + pattern + "\" " + msg,
+	}
+}
+
+// compileNaming compile a naming pattern, page fields are not allowed
+// in pattern if canUsePage is false. In a field, modifiers are split by
+// ":" or "|", and "\" escape the next character like "\:" or "\>".
+func compileNaming(pattern string, canUsePage bool) (*namingPattern, error) {
+	var (
+		np   = &namingPattern{Pattern: pattern}
+		rest = pattern
+	)
+	
+	for len(rest) > 0 {
+		var (
+			part        namingPart
+			isField     bool
+			start, stop int
+			items       []string
+			err         error
+		)
+		
+		// Text before "<" is a text part.
+		if start = strings.Index(rest, "<"); start < 0 {
+			np.Parts = append(np.Parts, namingPart{Text: rest})
+			break
+		} else if start > 0 {
+			np.Parts = append(np.Parts, namingPart{Text: rest[:start]})
+		}
+		if items, stop = splitNamingField(rest[start+1:]); stop < 0 {
+			return nil, throwNaming(pattern, "has an unclosed \"<\"")
+		}
+		rest = rest[start+1+stop+1:]
+		
+		// Field in "<" and ">" must exist and can be used in naming.
+		var name = strings.TrimSpace(items[0])
+		if part.Field, isField = namingFields[name]; !isField {
+			return nil, throwNaming(pattern, "has an unknown field \"<"+
+					name+ ">\"")
+		}
+		if !part.Field.CanUse {
+			return nil, throwNaming(pattern, "has a field \"<"+
+					name+ ">\" that can not be used in naming")
+		}
+		if !canUsePage && part.Field.Owner == reflect.TypeOf(PageData{}) {
+			return nil, throwNaming(pattern, "has a page field \"<"+
+					name+ ">\" that can not be used here")
+		}
+		
+		// Parse modifiers of field.
+		if part.Modifier, err = parseNamingModifier(
+			part.Field, items[1:]); err != nil {
+			return nil, throwNaming(pattern, "has a field \"<"+
+					name+ ">\" with "+ err.Error())
+		}
+		np.Parts = append(np.Parts, part)
+	}
+	
+	return np, nil
+}
+
+// splitNamingField split the content of field after "<" by ":" and "|"
+// until ">", return items and the index of ">" or -1 if it is not found.
+func splitNamingField(str string) (items []string, stop int) {
+	var (
+		item      strings.Builder
+		isEscaped = false
+	)
+	for i, c := range str {
+		switch {
+		case isEscaped:
+			item.WriteRune(c)
+			isEscaped = false
+		case c == '\\':
+			isEscaped = true
+		case c == ':' || c == '|':
+			items = append(items, item.String())
+			item.Reset()
+		case c == '>':
+			return append(items, item.String()), i
+		default:
+			item.WriteRune(c)
+		}
+	}
+	return nil, -1
+}
+
+// parseNamingModifier parse modifiers of a field, a modifier is "key=value"
+// or a format. A format is a layout for time or a width for number, the
+// number is padded with "0" if width start with "0".
+func parseNamingModifier(field *namingField,
+		items []string) (modifier namingModifier, err error) {
+	var (
+		fieldType = field.Owner.Field(field.Index).Type
+		isTime    = fieldType == reflect.TypeOf(time.Time{})
+		isNumber  = fieldType.Kind() == reflect.Uint64
+		isList    = fieldType.Kind() == reflect.Slice
+	)
+	
+	modifier.Join = ","
+	for _, item := range items {
+		var key, value = item, ""
+		if i := strings.Index(item, "="); i >= 0 {
+			key, value = item[:i], item[i+1:]
+		}
+		
+		switch {
+		case key == "trunc":
+			if modifier.Trunc, err = parseNamingNumber(key, value); err != nil {
+				return modifier, err
+			}
+		case key == "first" && isList:
+			if modifier.First, err = parseNamingNumber(key, value); err != nil {
+				return modifier, err
+			}
+		case key == "join" && isList:
+			modifier.Join = value
+		case key == "default":
+			modifier.Default = value
+		case value == "" && isTime:
+			// A layout with ":" like "15:04" is split if ":" is not
+			// escaped, so the second layout is an error.
+			if modifier.Layout != "" {
+				return modifier, errors.New("more than one layout \"" +
+						modifier.Layout + "\" and \"" + item +
+						"\", \":\" in layout should be escaped like \"\\:\"")
+			}
+			if (time.Time{}).Format(item) == item {
+				return modifier, errors.New("layout \"" + item +
+						"\" that does not have any element of time")
+			}
+			modifier.Layout = item
+		case value == "" && isNumber:
+			if modifier.Width != 0 {
+				return modifier, errors.New("more than one width")
+			}
+			if modifier.Width, err = parseNamingNumber(
+				"width", item); err != nil {
+				return modifier, err
+			}
+			modifier.PadZero = strings.HasPrefix(item, "0")
+		default:
+			return modifier, errors.New("unknown modifier \"" + item + "\"")
+		}
+	}
+	
+	return modifier, nil
+}
+
+// parseNamingNumber parse the value of a modifier that must be a number.
+func parseNamingNumber(key, value string) (int, error) {
+	var number, err = strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		return 0, errors.New("modifier \"" + key +
+				"\" that is not a positive number")
+	}
+	return number, nil
+}
+
+// render make a name from naming pattern with data of artist, work and page,
+// values of fields are sanitized by sanitizeValue with policy.
+func (np *namingPattern) render(artistData *ArtistData,
+		workData *WorkData, pageData *PageData, policy string) string {
+	var (
+		name strings.Builder
+		data = map[reflect.Type]reflect.Value{
+			reflect.TypeOf(ArtistData{}): reflect.ValueOf(artistData),
+			reflect.TypeOf(WorkData{}):   reflect.ValueOf(workData),
+			reflect.TypeOf(PageData{}):   reflect.ValueOf(pageData),
+		}
+	)
+	
+	for _, part := range np.Parts {
+		if part.Field == nil {
+			name.WriteString(part.Text)
+			continue
+		}
+		var value = data[part.Field.Owner]
+		if value.IsNil() {
+			continue
+		}
+		name.WriteString(sanitizeValue(part.Modifier.format(
+			value.Elem().Field(part.Field.Index).Interface()), policy))
+	}
+	
+	return name.String()
+}
+
+// format format a value of field to string with modifiers.
+func (nm *namingModifier) format(value interface{}) (str string) {
+	switch v := value.(type) {
+	case string:
+		str = v
+	case time.Time:
+		if nm.Layout != "" {
+			str = v.Format(nm.Layout)
+		} else {
+			str = v.Format("2006-01-02")
+		}
+	case uint64:
+		str = strconv.FormatUint(v, 10)
+		if pad := " "; len(str) < nm.Width {
+			if nm.PadZero {
+				pad = "0"
+			}
+			str = strings.Repeat(pad, nm.Width-len(str)) + str
+		}
+	case []string:
+		if nm.First > 0 && len(v) > nm.First {
+			v = v[:nm.First]
+		}
+		str = strings.Join(v, nm.Join)
+	default:
+		str = fmt.Sprint(v)
+	}
+	
+	if runes := []rune(str); nm.Trunc > 0 && len(runes) > nm.Trunc {
+		str = string(runes[:nm.Trunc])
+	}
+	if str == "" {
+		str = nm.Default
+	}
+	return str
+}
+
+// compile compile all naming patterns in Naming.
+func (n *Naming) compile() (err error) {
+	if err = n.NamingRule.compile(); err != nil {
+		return err
+	}
+	for _, rule := range []*NamingRule{n.Illust, n.Ugoira, n.Manga} {
+		if rule == nil {
+			continue
+		}
+		if err = rule.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getRule get the naming rule for the type of work, or the default rule
+// if the type of work does not have its rule.
+func (n *Naming) getRule(workType WorkType) (rule *NamingRule) {
+	switch workType {
+	case Illust:
+		rule = n.Illust
+	case Ugoira:
+		rule = n.Ugoira
+	case Manga:
+		rule = n.Manga
+	}
+	if rule == nil {
+		rule = &n.NamingRule
+	}
+	return rule
+}
+
+// getFilePath get the path of a page of work from naming patterns of its
+// type. A work with one page is saved as a single file, otherwise pages
+// are saved in a folder. The extension of image is added if name does
+// not have it.
+func (n *Naming) getFilePath(artistData *ArtistData, workData *WorkData,
+		pageData *PageData, policy string) string {
+	var (
+		name string
+		ext  = path.Ext(pageData.ImageURL)
+		rule = n.getRule(workData.Type)
+	)
+	if workData.PageCount > 1 || len(workData.Pages) > 1 {
+		name = path.Join(
+			rule.folder.render(artistData, workData, nil, policy),
+			rule.multipleFile.render(artistData, workData, pageData, policy))
+	} else {
+		name = rule.singleFile.render(artistData, workData, pageData, policy)
+	}
+	if !strings.EqualFold(path.Ext(name), ext) {
+		name += ext
+	}
+	return name
+}
+
+// isFilled check fields of artist and work used by naming patterns are
+// filled or not, fields with a default value or with keys in given like
+// "work.type" are not checked.
+func (nr *NamingRule) isFilled(artistData *ArtistData,
+		workData *WorkData, given map[string]bool) bool {
+	var data = map[reflect.Type]reflect.Value{
+		reflect.TypeOf(ArtistData{}): reflect.ValueOf(artistData).Elem(),
+		reflect.TypeOf(WorkData{}):   reflect.ValueOf(workData).Elem(),
+	}
+	for _, np := range []*namingPattern{
+		nr.singleFile, nr.multipleFile, nr.folder} {
+		for _, part := range np.Parts {
+			if part.Field == nil || part.Modifier.Default != "" {
+				continue
+			}
+			var value, isData = data[part.Field.Owner]
+			if !isData || given[getFieldKey(part.Field.Owner,
+				part.Field.Index)] {
+				continue
+			}
+			var field = value.Field(part.Field.Index)
+			if reflect.DeepEqual(field.Interface(),
+				reflect.Zero(field.Type()).Interface()) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// compile compile naming patterns in NamingRule.
+func (nr *NamingRule) compile() (err error) {
+	if nr.singleFile, err = compileNaming(nr.SingleFile, true); err != nil {
+		return err
+	}
+	if nr.multipleFile, err = compileNaming(
+		nr.MultipleFile, true); err != nil {
+		return err
+	}
+	if nr.folder, err = compileNaming(nr.Folder, false); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package pixiv
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestWork make data of a work with two pages for naming tests.
+func newTestWork(workType WorkType) (*ArtistData, *WorkData) {
+	return &ArtistData{ID: "1", Nickname: "Artist"}, &WorkData{
+		ID:        "100",
+		Name:      "作品の名前",
+		Time:      time.Date(2018, 1, 2, 12, 30, 0, 0, time.UTC),
+		PageCount: 2,
+		Tags:      []string{"a", "b", "c"},
+		Type:      workType,
+		Pages: []PageData{
+			{Page: 0, ImageURL: "https://i.pximg.net/img/100_p0.jpg"},
+			{Page: 7, ImageURL: "https://i.pximg.net/img/100_p7.jpg"},
+		},
+	}
+}
+
+func TestCompileNaming(t *testing.T) {
+	for _, test := range []struct {
+		pattern    string
+		canUsePage bool
+		name       string
+		errMsg     string
+	}{
+		{"<work.id>", true, "100", ""},
+		{"(<work.id>) <artist.name>", true, "(100) Artist", ""},
+		{"<work.time>", true, "2018-01-02", ""},
+		{"<work.time:2006-01-02>", true, "2018-01-02", ""},
+		{`<work.time:2006-01-02 15\:04>`, true, "2018-01-02 12:30", ""},
+		{"<work.page:03>", true, "007", ""},
+		{"<work.page:3>", true, "  7", ""},
+		{"<work.name:trunc=2>", true, "作品", ""},
+		{"<work.tags>", true, "a,b,c", ""},
+		{"<work.tags:join=+:first=2>", true, "a+b", ""},
+		{"<work.series|default=no-series>", true, "no-series", ""},
+		{`<work.name\>>`, true, "", "unknown field"},
+		{"<work.time:2006-01-02 15:04>", true, "", "more than one layout"},
+		{"<work.time:date>", true, "", "does not have any element of time"},
+		{"<work.page:03:04>", true, "", "more than one width"},
+		{"<work.name:trunc=0>", true, "", "not a positive number"},
+		{"<work.id:03>", true, "", "unknown modifier \"03\""},
+		{"<work.name:join=+>", true, "", "unknown modifier \"join=+\""},
+		{"<work.caption>", true, "", "can not be used in naming"},
+		{"<work.unknown>", true, "", "unknown field"},
+		{"<work.id", true, "", "unclosed"},
+		{"<work.page>", false, "", "page field"},
+	} {
+		t.Run(test.pattern, func(t *testing.T) {
+			var (
+				artistData, workData = newTestWork(Illust)
+				np, err              = compileNaming(test.pattern,
+					test.canUsePage)
+			)
+			if test.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), test.errMsg) {
+					t.Errorf("error %v does not contain %q", err, test.errMsg)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if name := np.render(artistData, workData, &workData.Pages[1],
+				PosixPolicy); name != test.name {
+				t.Errorf("name is %q, not %q", name, test.name)
+			}
+		})
+	}
+}
+
+// TestNamingGetFilePath check the naming rule of a type of work is used,
+// and types without their rules use the default rule.
+func TestNamingGetFilePath(t *testing.T) {
+	var naming = Naming{
+		NamingRule: NamingRule{
+			SingleFile:   "<work.id>",
+			MultipleFile: "<work.page>",
+			Folder:       "<artist.name>/<work.id>",
+		},
+		Manga: &NamingRule{
+			SingleFile:   "manga/<work.id>",
+			MultipleFile: "p<work.page:02>",
+			Folder:       "manga/<work.id>",
+		},
+	}
+	if err := naming.compile(); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		workType  WorkType
+		pageCount uint64
+		path      string
+	}{
+		{Illust, 1, "100.jpg"},
+		{Illust, 2, "Artist/100/7.jpg"},
+		{Ugoira, 2, "Artist/100/7.jpg"},
+		{Manga, 1, "manga/100.jpg"},
+		{Manga, 2, "manga/100/p07.jpg"},
+	} {
+		var artistData, workData = newTestWork(test.workType)
+		workData.PageCount = test.pageCount
+		if test.pageCount == 1 {
+			workData.Pages = workData.Pages[1:]
+		}
+		if path := naming.getFilePath(artistData, workData,
+			&workData.Pages[len(workData.Pages)-1],
+			PosixPolicy); path != test.path {
+			t.Errorf("path of %v with %d page(s) is %q, not %q",
+				test.workType, test.pageCount, path, test.path)
+		}
+	}
+}
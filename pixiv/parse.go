@@ -0,0 +1,119 @@
+package pixiv
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SnippetLength is the max length in bytes of the snippet in ParseError.
+const SnippetLength = 120
+
+// A ParseError is an error that a field can not be found or parsed in
+// the input from Pixiv, it usually means the layout of Pixiv is changed.
+type ParseError struct {
+	// Prefix is the name of what parse the input like "html" or "login".
+	Prefix string
+	// Field is the name of field, Pattern is the regular expression or
+	// the format used to find or parse it.
+	Field   string
+	Pattern string
+	// WorkID is the ID of work if the input is data of a work.
+	WorkID string
+	// Snippet is a part of input near where the field should be.
+	Snippet string
+	// Err is the error that caused by parsing, it can be nil.
+	Err error
+}
+
+// Error is needed when implement an error interface.
+func (pe *ParseError) Error() string {
+	var msg = pe.Prefix + ": failed to parse \"" + pe.Field + "\""
+	if pe.WorkID != "" {
+		msg += " of work \"" + pe.WorkID + "\""
+	}
+	if pe.Pattern != "" {
+		msg += " by " + strconv.Quote(pe.Pattern)
+	}
+	if pe.Err != nil {
+		msg += ": " + pe.Err.Error()
+	}
+	return msg + ", near " + strconv.Quote(pe.Snippet)
+}
+
+// Unwrap get the error that caused by parsing.
+func (pe *ParseError) Unwrap() error { return pe.Err }
+
+// getSnippet get a part of input that start from where the literal prefix
+// of pattern is found, or the start of input if it is not found. Spaces
+// are collapsed and it is cut on a rune boundary.
+func getSnippet(input string, pattern *regexp.Regexp) string {
+	if pattern != nil {
+		if prefix, _ := pattern.LiteralPrefix(); prefix != "" {
+			if i := strings.Index(input, prefix); i >= 0 {
+				input = input[i:]
+			}
+		}
+	}
+	if len(input) > SnippetLength*4 {
+		input = input[:SnippetLength*4]
+	}
+	input = strings.Join(strings.Fields(input), " ")
+	if len(input) > SnippetLength {
+		var i = SnippetLength
+		for i > 0 && !utf8.RuneStart(input[i]) {
+			i--
+		}
+		input = input[:i] + "..."
+	}
+	return input
+}
+
+// A matcher find fields of a work in input by regular expressions, and
+// return ParseError if a required field is not found.
+type matcher struct {
+	Prefix string
+	WorkID string
+}
+
+// throw make a ParseError of field in input.
+func (m *matcher) throw(field, input string, pattern *regexp.Regexp,
+		err error) error {
+	var parseErr = &ParseError{
+		Prefix:  m.Prefix,
+		Field:   field,
+		WorkID:  m.WorkID,
+		Snippet: getSnippet(input, pattern),
+		Err:     err,
+	}
+	if pattern != nil {
+		parseErr.Pattern = pattern.String()
+	}
+	return parseErr
+}
+
+// match get the first submatch of pattern in input of a required field.
+func (m *matcher) match(field, input, pattern string) (string, error) {
+	var re = regexp.MustCompile(pattern)
+	if match := re.FindStringSubmatch(input); len(match) > 1 {
+		return match[1], nil
+	}
+	return "", m.throw(field, input, re, nil)
+}
+
+// matchOptional get the first submatch of pattern in input of an optional
+// field, it return an empty string if it is not found.
+func (m *matcher) matchOptional(input, pattern string) string {
+	if match := regexp.MustCompile(
+		pattern).FindStringSubmatch(input); len(match) > 1 {
+		return match[1]
+	}
+	return ""
+}
+
+// matchAll get all submatches of pattern in input, it return nil if it is
+// not found.
+func (m *matcher) matchAll(input, pattern string) [][]string {
+	return regexp.MustCompile(pattern).FindAllStringSubmatch(input, -1)
+}
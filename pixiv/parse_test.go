@@ -0,0 +1,126 @@
+package pixiv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorError(t *testing.T) {
+	for _, test := range []struct {
+		err *ParseError
+		msg string
+	}{
+		{&ParseError{Prefix: HTMLSource, Field: "name", Snippet: "<h1>"},
+			`html: failed to parse "name", near "<h1>"`},
+		{&ParseError{Prefix: HTMLSource, Field: "name", Pattern: `<h1>(.*?)<`,
+			WorkID: "100", Snippet: "<h2>"},
+			`html: failed to parse "name" of work "100" by "<h1>(.*?)<", ` +
+					`near "<h2>"`},
+		{&ParseError{Prefix: AJAXSource, Field: "time", WorkID: "100",
+			Err: errors.New("bad time"), Snippet: `"x"`},
+			`ajax: failed to parse "time" of work "100": bad time, ` +
+					`near "\"x\""`},
+	} {
+		if msg := test.err.Error(); msg != test.msg {
+			t.Errorf("message is %q, not %q", msg, test.msg)
+		}
+	}
+}
+
+func TestGetSnippet(t *testing.T) {
+	var long = strings.Repeat("あ", SnippetLength)
+	for _, test := range []struct {
+		input   string
+		pattern *regexp.Regexp
+		snippet string
+	}{
+		{"a\n  b\tc", nil, "a b c"},
+		{"<head></head><h1 class=\"x\">", regexp.MustCompile(
+			`<h1 class="(.*?)">`), `<h1 class="x">`},
+		{"abc", regexp.MustCompile(`<h1>(.*?)<`), "abc"},
+		// A rune of 3 bytes is not split.
+		{long, nil, strings.Repeat("あ", SnippetLength/3) + "..."},
+	} {
+		if snippet := getSnippet(test.input,
+			test.pattern); snippet != test.snippet {
+			t.Errorf("snippet of %q is %q, not %q",
+				test.input, snippet, test.snippet)
+		}
+	}
+}
+
+// TestMatcherMatchHTML check a field that is not found in malformed HTML
+// is a ParseError.
+func TestMatcherMatchHTML(t *testing.T) {
+	var (
+		m         = &matcher{Prefix: HTMLSource, WorkID: "100"}
+		html      = "<html><body>\n<h2 class=\"title\">Work</h2></body>"
+		_, err    = m.match("name", html, `<h1 class="title">(.+?)</h1>`)
+		parseErr *ParseError
+	)
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("error %v is not a ParseError", err)
+	}
+	if parseErr.Field != "name" || parseErr.WorkID != "100" ||
+			parseErr.Snippet != "<html><body> <h2 class=\"title\">Work</h2></body>" {
+		t.Errorf("ParseError is %+v", parseErr)
+	}
+	if msg := err.Error(); msg != `html: failed to parse "name" of work `+
+			`"100" by "<h1 class=\"title\">(.+?)</h1>", near "<html><body> `+
+			`<h2 class=\"title\">Work</h2></body>"` {
+		t.Errorf("message is %q", msg)
+	}
+}
+
+// TestGetAJAXBodyParseError check malformed JSON from AJAX API is a
+// ParseError that wrap the error of JSON.
+func TestGetAJAXBodyParseError(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		body string
+		msg  string
+	}{
+		{"Response", `{"error": false, "body": `,
+			`near "{\"error\": false, \"body\":"`},
+		{"Body", `{"error": false, "body": {"id": 100}}`,
+			`near "{\"id\": 100}"`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var server = httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, req *http.Request) {
+					w.Write([]byte(test.body))
+				}))
+			defer server.Close()
+			var (
+				body struct {
+					ID string `json:"id"`
+				}
+				err = getAJAXBody(context.Background(),
+					newTestClient(t, server), server.URL, "work", &body)
+				parseErr *ParseError
+			)
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("error %v is not a ParseError", err)
+			}
+			var (
+				syntaxErr *json.SyntaxError
+				typeErr   *json.UnmarshalTypeError
+			)
+			if !errors.As(err, &syntaxErr) && !errors.As(err, &typeErr) {
+				t.Errorf("error %v does not wrap the error of JSON", err)
+			}
+			// The message of the error of JSON may be changed by Go.
+			if msg := err.Error(); !strings.HasPrefix(msg,
+				"ajax: failed to parse \""+ server.URL+ "\" by \"JSON\": ") ||
+					!strings.HasSuffix(msg, test.msg) {
+				t.Errorf("message %q does not end with %q", msg, test.msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Package pixiv log in to Pixiv, get data of works and download them. It
+// is the library of pixiv_tool, and can be used without the command.
+//
+// A Client send requests to Pixiv with retries, rate limits and a cookie
+// jar, a Session log in to and log out from Pixiv by the Client, and a
+// Downloader download works from IDs, URLs or list files by the Client
+// with DownloadOptions:
+//
+//     var client = pixiv.NewClient()
+//     client.CookieFile = ".cookie"
+//     client.Logger = log.New(os.Stderr, "", log.LstdFlags)
+//     if err := client.Init(); err != nil {
+//     	return err
+//     }
+//     defer client.Close()
+//     var downloader, err = pixiv.NewDownloader(client,
+//     	pixiv.DefaultDownloadOptions())
+//     if err != nil {
+//     	return err
+//     }
+//     return downloader.Download(ctx, "12345678")
+package pixiv
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"runtime"
+	"strconv"
+)
+
+const (
+	AppName           = "pixiv_tool"
+	UserAgentFmt      = "Mozilla/5.0 (%s rv:%d.0) Gecko/%s Firefox/%d.0"
+	PixivHomeURL      = "https://www.pixiv.net/"
+	PixivLoginURL     = "https://accounts.pixiv.net/login?lang=ja&source=pc&view_type=page&ref=wwwtop_accounts_index"
+	PixivLogoutURL    = PixivHomeURL + "logout.php?return_to=%2F"
+	PixivWorkURL      = PixivHomeURL + "member_illust.php?mode=medium&illust_id=%s"
+	PixivMangaURL     = PixivHomeURL + "member_illust.php?mode=manga_big&illust_id=%s&page=%d"
+	PixivUserWorksURL = PixivHomeURL + "ajax/user/%s/profile/all"
+	PixivUserDataURL  = PixivHomeURL + "ajax/user/%s"
+	PixivWorkDataURL  = PixivHomeURL + "ajax/illust/%s"
+	PixivWorkPagesURL = PixivHomeURL + "ajax/illust/%s/pages"
+)
+
+// An AppError is a implementation of error interface for this app.
+type AppError struct {
+	Prefix string
+	Msg    string
+}
+
+// Error is needed when implement an error interface.
+func (ae *AppError) Error() string { return ae.Prefix + ": " + ae.Msg }
+
+// throw return an error interface made by AppError.
+func throw(prefix, msg string) error {
+	return &AppError{Prefix: prefix, Msg: msg}
+}
+
+// logf print a log message with the prefix like AppError to
+// Client.Logger, nothing is printed if it is nil.
+func (c *Client) logf(prefix, format string, a ...interface{}) {
+	if c != nil && c.Logger != nil {
+		c.Logger.Printf(prefix+": "+format, a...)
+	}
+}
+
+// GetUserAgent get default user agent of this app in each os.
+func GetUserAgent() string {
+	// Value of browserVer and userAgentOS must update regularly.
+	var browserVer uint32 = 59
+	var userAgentOS, geckoVer = func() (string, string) {
+		switch runtime.GOOS {
+		case "windows":
+			return "Windows NT 10.0; Win64; x64;", "20100101"
+		case "darwin":
+			return "Macintosh; Intel Mac OS X 10.13;", "20100101"
+		case "android":
+			return "Android 8.1.0; Tablet;",
+					strconv.FormatUint(uint64(browserVer), 10) + ".0"
+		default:
+			return "X11; Linux x86_64;", "20100101"
+		}
+	}()
+	return fmt.Sprintf(UserAgentFmt,
+		userAgentOS, browserVer, geckoVer, browserVer)
+}
+
+// getResponseBody get string of body from http response.
+func getResponseBody(resp *http.Response) (string, error) {
+	var bodyBytes, err = ioutil.ReadAll(resp.Body)
+	return string(bodyBytes), err
+}
+
+// checkIsLoggedIn check that this app is logged in on Pixiv or not, the
+// error of failedMsg has the prefix.
+func checkIsLoggedIn(resp *http.Response, prefix, failedMsg string) (_ bool, err error) {
+	var body string
+	if resp.StatusCode != http.StatusOK {
+		return false, throw(prefix, failedMsg)
+	}
+	if body, err = getResponseBody(resp); err != nil {
+		return false, err
+	}
+	return regexp.MustCompile(`class="user"`).MatchString(body), nil
+}
@@ -0,0 +1,101 @@
+package pixiv
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// errJobSkipped is the result of a job that is not started because ctx is
+// done.
+var errJobSkipped = errors.New("job skipped")
+
+// An ErrorList is the errors of jobs that failed in order of jobs.
+type ErrorList []error
+
+// Error is needed when implement an error interface.
+func (el ErrorList) Error() string {
+	var msgs = make([]string, len(el))
+	for i, err := range el {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is check any error in ErrorList is target or not for errors.Is.
+func (el ErrorList) Is(target error) bool {
+	for _, err := range el {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// runJobs run jobs from 0 to n-1 with at most workers jobs at the same
+// time, and call report in order of jobs after each job is done even if
+// later jobs are done earlier. A failed job does not stop other jobs, the
+// error of it is returned, or an ErrorList of all errors in order of jobs
+// is returned if more than one job failed. After ctx is done, jobs that
+// are not started are skipped and not reported, and the error of ctx is
+// added to the returned errors. Running jobs should stop by themselves
+// when ctx is done.
+func runJobs(ctx context.Context, n, workers int, job func(i int) error,
+		report func(i int, err error)) error {
+	var (
+		results = make([]chan error, n)
+		tokens  chan struct{}
+		errs    ErrorList
+		skipped bool
+	)
+	
+	if workers < 1 {
+		workers = 1
+	}
+	tokens = make(chan struct{}, workers)
+	for i := range results {
+		results[i] = make(chan error, 1)
+	}
+	
+	// Start jobs in order when a worker is free.
+	go func() {
+		for i := 0; i < n; i++ {
+			tokens <- struct{}{}
+			if ctx.Err() != nil {
+				results[i] <- errJobSkipped
+				<-tokens
+				continue
+			}
+			go func(i int) {
+				defer func() { <-tokens }()
+				results[i] <- job(i)
+			}(i)
+		}
+	}()
+	
+	// Wait and report results in order.
+	for i := 0; i < n; i++ {
+		var jobErr = <-results[i]
+		if jobErr == errJobSkipped {
+			skipped = true
+			continue
+		}
+		if jobErr != nil {
+			errs = append(errs, jobErr)
+		}
+		if report != nil {
+			report(i, jobErr)
+		}
+	}
+	if skipped && !errs.Is(ctx.Err()) {
+		errs = append(errs, ctx.Err())
+	}
+	
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return errs
+}
@@ -0,0 +1,58 @@
+package pixiv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// TestRunJobs check failed jobs do not stop other jobs, and all errors are
+// returned in order of jobs.
+func TestRunJobs(t *testing.T) {
+	for _, workers := range []int{1, 3} {
+		var (
+			reported []int
+			err      = runJobs(context.Background(), 5, workers,
+				func(i int) error {
+					if i%2 == 1 {
+						return fmt.Errorf("job %d", i)
+					}
+					return nil
+				}, func(i int, err error) {
+					reported = append(reported, i)
+				})
+		)
+		if !reflect.DeepEqual(reported, []int{0, 1, 2, 3, 4}) {
+			t.Errorf("reported jobs with %d worker(s) are %v",
+				workers, reported)
+		}
+		if err == nil || err.Error() != "job 1; job 3" {
+			t.Errorf("error with %d worker(s) is %v, not \"job 1; job 3\"",
+				workers, err)
+		}
+	}
+}
+
+// TestRunJobsCanceled check jobs that are not started are skipped after
+// ctx is done, and the error of ctx is returned.
+func TestRunJobsCanceled(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		started     []int
+		jobErr      = errors.New("job 0")
+	)
+	defer cancel()
+	var err = runJobs(ctx, 3, 1, func(i int) error {
+		started = append(started, i)
+		cancel()
+		return jobErr
+	}, nil)
+	if !reflect.DeepEqual(started, []int{0}) {
+		t.Errorf("started jobs are %v, not [0]", started)
+	}
+	if !errors.Is(err, jobErr) || !errors.Is(err, context.Canceled) {
+		t.Errorf("error %v is not %v and %v", err, jobErr, context.Canceled)
+	}
+}
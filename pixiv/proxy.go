@@ -0,0 +1,109 @@
+package pixiv
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// getEnv get the first environment variable in names that is not empty.
+func getEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// getProxyURL get the URL of proxy from Client.Proxy, or from environment
+// variables HTTPS_PROXY, HTTP_PROXY and ALL_PROXY if it is empty. The
+// scheme can be "http", "https", "socks5" or "socks5h", and "http" is used
+// if it is not given. Client.ProxyUser and Client.ProxyPassword replace
+// the user in the URL if they are set. It return nil if no proxy is used.
+func (c *Client) getProxyURL() (proxyURL *url.URL, err error) {
+	var proxy = c.Proxy
+	if proxy == "" {
+		proxy = getEnv("HTTPS_PROXY", "https_proxy",
+			"HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy")
+	}
+	if proxy == "" {
+		return nil, nil
+	}
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	
+	// The proxy is not in the error because it may have the password.
+	var throwErr = &AppError{Prefix: "client", Msg: "proxy is not valid"}
+	if proxyURL, err = url.Parse(proxy); err != nil || proxyURL.Host == "" {
+		return nil, throwErr
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, &AppError{Prefix: "client", Msg: "scheme of proxy \"" +
+				proxyURL.Scheme + "\" is not supported"}
+	}
+	if c.ProxyUser != "" {
+		proxyURL.User = url.UserPassword(c.ProxyUser, c.ProxyPassword)
+	}
+	return proxyURL, nil
+}
+
+// getNoProxyHosts get hosts that do not use proxy split by "," from
+// Client.NoProxy, or from environment variable NO_PROXY if it is empty.
+func (c *Client) getNoProxyHosts() (hosts []string) {
+	var noProxy = c.NoProxy
+	if noProxy == "" {
+		noProxy = getEnv("NO_PROXY", "no_proxy")
+	}
+	for _, host := range strings.Split(noProxy, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// isNoProxyHost check the host matches any of hosts or not. A host in
+// hosts matches itself and its subdomains, a leading "." or "*." of it is
+// ignored, and "*" matches all hosts.
+func isNoProxyHost(host string, hosts []string) bool {
+	host = strings.ToLower(host)
+	for _, noProxyHost := range hosts {
+		if noProxyHost == "*" {
+			return true
+		}
+		if h, _, err := net.SplitHostPort(noProxyHost); err == nil {
+			noProxyHost = h
+		}
+		noProxyHost = strings.TrimPrefix(
+			strings.TrimPrefix(noProxyHost, "*"), ".")
+		if host == noProxyHost || strings.HasSuffix(host, "."+noProxyHost) {
+			return true
+		}
+	}
+	return false
+}
+
+// getProxy get the proxy function for http.Transport from the proxy
+// config of Client, it return nil if no proxy is used.
+func (c *Client) getProxy() (_ func(*http.Request) (*url.URL, error),
+		err error) {
+	var (
+		proxyURL     *url.URL
+		noProxyHosts = c.getNoProxyHosts()
+	)
+	if proxyURL, err = c.getProxyURL(); err != nil || proxyURL == nil {
+		return nil, err
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if isNoProxyHost(req.URL.Hostname(), noProxyHosts) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}, nil
+}
@@ -0,0 +1,142 @@
+package pixiv
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// clearProxyEnv clear environment variables of proxy during the test.
+func clearProxyEnv(t *testing.T) {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY",
+		"http_proxy", "ALL_PROXY", "all_proxy", "NO_PROXY", "no_proxy"} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestClientGetProxy(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		client Client
+		env    map[string]string
+		url    string
+		proxy  string
+		errMsg string
+	}{
+		{"None", Client{}, nil, "https://www.pixiv.net/", "", ""},
+		{"Explicit", Client{Proxy: "http://proxy:8080"}, nil,
+			"https://www.pixiv.net/", "http://proxy:8080", ""},
+		{"NoScheme", Client{Proxy: "proxy:8080"}, nil,
+			"https://www.pixiv.net/", "http://proxy:8080", ""},
+		{"Socks5", Client{Proxy: "socks5://proxy:1080"}, nil,
+			"https://i.pximg.net/", "socks5://proxy:1080", ""},
+		{"User", Client{Proxy: "socks5h://proxy:1080", ProxyUser: "u",
+			ProxyPassword: "p"}, nil,
+			"https://www.pixiv.net/", "socks5h://u:p@proxy:1080", ""},
+		{"ExplicitOverEnv", Client{Proxy: "http://proxy:8080"},
+			map[string]string{"HTTPS_PROXY": "http://env:3128"},
+			"https://www.pixiv.net/", "http://proxy:8080", ""},
+		{"Env", Client{}, map[string]string{"HTTPS_PROXY": "http://env:3128",
+			"HTTP_PROXY": "http://other:3128"},
+			"https://www.pixiv.net/", "http://env:3128", ""},
+		{"HTTPEnv", Client{}, map[string]string{
+			"http_proxy": "http://env:3128"},
+			"https://www.pixiv.net/", "http://env:3128", ""},
+		{"NoProxy", Client{Proxy: "http://proxy:8080",
+			NoProxy: "example.com, .pixiv.net"}, nil,
+			"https://accounts.pixiv.net/", "", ""},
+		{"NoProxyOther", Client{Proxy: "http://proxy:8080",
+			NoProxy: "pixiv.net"}, nil,
+			"https://i.pximg.net/", "http://proxy:8080", ""},
+		{"NoProxyEnv", Client{}, map[string]string{
+			"HTTPS_PROXY": "http://env:3128", "NO_PROXY": "*.pximg.net"},
+			"https://i.pximg.net/", "", ""},
+		{"NoProxyAll", Client{Proxy: "http://proxy:8080", NoProxy: "*"},
+			nil, "https://www.pixiv.net/", "", ""},
+		{"Scheme", Client{Proxy: "ftp://proxy:21"}, nil, "", "",
+			"scheme of proxy \"ftp\" is not supported"},
+		{"Invalid", Client{Proxy: "http://"}, nil, "", "",
+			"proxy is not valid"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			clearProxyEnv(t)
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+			var proxy, err = test.client.getProxy()
+			if test.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), test.errMsg) {
+					t.Errorf("error %v does not contain %q", err, test.errMsg)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			} else if proxy == nil {
+				if test.proxy != "" {
+					t.Errorf("proxy is nil, not %q", test.proxy)
+				}
+				return
+			}
+			var req, _ = http.NewRequest("GET", test.url, nil)
+			var proxyURL, _ = proxy(req)
+			if proxyURL == nil && test.proxy != "" ||
+					proxyURL != nil && proxyURL.String() != test.proxy {
+				t.Errorf("proxy of %q is %v, not %q",
+					test.url, proxyURL, test.proxy)
+			}
+		})
+	}
+}
+
+// TestClientProxyServer check requests are sent through the proxy server
+// except hosts in Client.NoProxy.
+func TestClientProxyServer(t *testing.T) {
+	var (
+		proxyServer = httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, req *http.Request) {
+				w.Write([]byte("proxy " + req.URL.String()))
+			}))
+		server = httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, req *http.Request) {
+				w.Write([]byte("server"))
+			}))
+	)
+	defer proxyServer.Close()
+	defer server.Close()
+	for _, test := range []struct {
+		noProxy string
+		body    string
+	}{
+		{"", "proxy " + server.URL + "/"},
+		{"127.0.0.1", "server"},
+		{"example.com", "proxy " + server.URL + "/"},
+	} {
+		clearProxyEnv(t)
+		var (
+			client = &Client{Proxy: proxyServer.URL,
+				NoProxy: test.noProxy}
+			transport = &http.Transport{}
+			resp      *http.Response
+			body      []byte
+			err       error
+		)
+		if transport.Proxy, err = client.getProxy(); err != nil {
+			t.Fatal(err)
+		}
+		if resp, err = (&http.Client{Transport: transport}).Get(
+			server.URL + "/"); err != nil {
+			t.Fatal(err)
+		}
+		body, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		} else if string(body) != test.body {
+			t.Errorf("body with NoProxy %q is %q, not %q",
+				test.noProxy, body, test.body)
+		}
+		transport.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,107 @@
+package pixiv
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A rateLimiter allow at most rate events per second, events are spread
+// evenly and shared by all goroutines that use it. A nil rateLimiter does
+// not limit anything.
+type rateLimiter struct {
+	mutex sync.Mutex
+	rate  float64
+	next  time.Time
+}
+
+// newRateLimiter make a rateLimiter that allow rate events per second, it
+// return nil if rate is not positive.
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: rate}
+}
+
+// wait wait until n events are allowed, it return the error of ctx if ctx
+// is done before that.
+func (rl *rateLimiter) wait(ctx context.Context, n int) error {
+	if rl == nil || n <= 0 {
+		return nil
+	}
+	rl.mutex.Lock()
+	var (
+		now   = time.Now()
+		start = rl.next
+	)
+	if start.Before(now) {
+		start = now
+	}
+	rl.next = start.Add(time.Duration(float64(n) / rl.rate *
+			float64(time.Second)))
+	rl.mutex.Unlock()
+	return sleep(ctx, time.Until(start))
+}
+
+// A rateLimitedBody is a body of response that is read at most the rate
+// of its rateLimiter bytes per second, until ctx of its request is done.
+type rateLimitedBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	limiter *rateLimiter
+}
+
+// Read read from the body, it read at most bytes of one second once so
+// the rate is smooth.
+func (rlb *rateLimitedBody) Read(p []byte) (n int, err error) {
+	if len(p) > int(rlb.limiter.rate) && rlb.limiter.rate >= 1 {
+		p = p[:int(rlb.limiter.rate)]
+	}
+	n, err = rlb.ReadCloser.Read(p)
+	if waitErr := rlb.limiter.wait(rlb.ctx, n); err == nil {
+		err = waitErr
+	}
+	return n, err
+}
+
+// isImageHost check the host of URL is a host of images like
+// "i.pximg.net" or not.
+func isImageHost(host string) bool {
+	host = strings.ToLower(host)
+	return host == "pximg.net" || strings.HasSuffix(host, ".pximg.net")
+}
+
+// initRateLimiters make rate limiters of Client from Client.PageRate,
+// Client.ImageRate and Client.ImageByteRate.
+func (c *Client) initRateLimiters() {
+	c.pageLimiter = newRateLimiter(c.PageRate)
+	c.imageLimiter = newRateLimiter(c.ImageRate)
+	c.imageByteLimiter = newRateLimiter(c.ImageByteRate)
+}
+
+// waitRate wait until the request is allowed by the rate limiter of its
+// host, requests to hosts of images use Client.ImageRate and other
+// requests use Client.PageRate.
+func (c *Client) waitRate(req *http.Request) error {
+	if isImageHost(req.URL.Hostname()) {
+		return c.imageLimiter.wait(req.Context(), 1)
+	}
+	return c.pageLimiter.wait(req.Context(), 1)
+}
+
+// limitBody limit the rate of reading the body of response from hosts of
+// images to Client.ImageByteRate bytes per second.
+func (c *Client) limitBody(resp *http.Response) {
+	if c.imageByteLimiter != nil && resp.Body != nil &&
+			isImageHost(resp.Request.URL.Hostname()) {
+		resp.Body = &rateLimitedBody{
+			ReadCloser: resp.Body,
+			ctx:        resp.Request.Context(),
+			limiter:    c.imageByteLimiter,
+		}
+	}
+}
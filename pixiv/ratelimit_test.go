@@ -0,0 +1,145 @@
+package pixiv
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	var (
+		limiter = newRateLimiter(100)
+		start   = time.Now()
+	)
+	// The first event is allowed at once, and others are every 10ms.
+	for i := 0; i < 5; i++ {
+		if err := limiter.wait(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("5 events at 100 per second are allowed in %v", elapsed)
+	}
+	
+	// A nil rateLimiter does not wait.
+	if limiter = newRateLimiter(0); limiter != nil {
+		t.Errorf("rate limiter of rate 0 is not nil")
+	}
+	start = time.Now()
+	for i := 0; i < 100; i++ {
+		limiter.wait(context.Background(), 1)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("nil rate limiter wait %v", elapsed)
+	}
+}
+
+func TestRateLimiterWaitCanceled(t *testing.T) {
+	var (
+		limiter     = newRateLimiter(1)
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+	cancel()
+	if err := limiter.wait(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := limiter.wait(ctx, 1); err != context.Canceled {
+		t.Errorf("error %v is not %v", err, context.Canceled)
+	}
+}
+
+func TestRateLimitedBody(t *testing.T) {
+	var (
+		data = strings.Repeat("0123456789", 5)
+		body = &rateLimitedBody{
+			ReadCloser: ioutil.NopCloser(strings.NewReader(data)),
+			ctx:        context.Background(),
+			limiter:    newRateLimiter(1000),
+		}
+		buf   = make([]byte, 10)
+		read  []byte
+		start = time.Now()
+	)
+	// Each read of 10 bytes waits 10ms at 1000 bytes per second.
+	for {
+		var n, err = body.Read(buf)
+		read = append(read, buf[:n]...)
+		if err != nil {
+			break
+		}
+	}
+	if string(read) != data {
+		t.Errorf("read %q, not %q", read, data)
+	}
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("50 bytes at 1000 bytes per second are read in %v", elapsed)
+	}
+	
+	// A read is not more than bytes of one second.
+	body = &rateLimitedBody{
+		ReadCloser: ioutil.NopCloser(strings.NewReader(data)),
+		ctx:        context.Background(),
+		limiter:    newRateLimiter(20),
+	}
+	if n, _ := body.Read(make([]byte, 100)); n != 20 {
+		t.Errorf("read %d bytes at 20 bytes per second, not 20", n)
+	}
+}
+
+func TestIsImageHost(t *testing.T) {
+	for host, isImage := range map[string]bool{
+		"i.pximg.net":        true,
+		"s.PXIMG.net":        true,
+		"pximg.net":          true,
+		"www.pixiv.net":      false,
+		"accounts.pixiv.net": false,
+		"notpximg.net":       false,
+	} {
+		if isImageHost(host) != isImage {
+			t.Errorf("%q is image host: %v, not %v", host, !isImage, isImage)
+		}
+	}
+}
+
+// TestClientWaitRate check requests to hosts of images and pages wait for
+// their own rate limiters.
+func TestClientWaitRate(t *testing.T) {
+	var client = &Client{PageRate: 10, ImageRate: 4, ImageByteRate: 10}
+	client.initRateLimiters()
+	for _, test := range []struct {
+		url      string
+		isWaited bool
+	}{
+		{"https://www.pixiv.net/", false},
+		{"https://i.pximg.net/img-original/img/100_p0.png", false},
+		{"https://accounts.pixiv.net/login", true},
+		{"https://s.pximg.net/common/images/no_profile.png", true},
+	} {
+		var (
+			req, _ = http.NewRequest("GET", test.url, nil)
+			start  = time.Now()
+		)
+		if err := client.waitRate(req); err != nil {
+			t.Fatal(err)
+		}
+		// Requests to pages are allowed every 100ms, and requests to
+		// images are allowed every 250ms.
+		if isWaited := time.Since(start) > 50*time.Millisecond;
+				isWaited != test.isWaited {
+			t.Errorf("request to %q is waited: %v, not %v",
+				test.url, isWaited, test.isWaited)
+		}
+		
+		// Only bodies of images are limited.
+		var resp = &http.Response{Request: req,
+			Body: ioutil.NopCloser(strings.NewReader(""))}
+		client.limitBody(resp)
+		if _, isLimited := resp.Body.(*rateLimitedBody);
+				isLimited != isImageHost(req.URL.Hostname()) {
+			t.Errorf("body from %q is limited: %v", test.url, isLimited)
+		}
+	}
+}
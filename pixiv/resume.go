@@ -0,0 +1,174 @@
+package pixiv
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// getPartFilename get the filename of the partial file of an image from URL,
+// it is in the same folder of filename and its name is short so it will
+// not be longer than MaxNameBytes. The validator of partial file is saved
+// in the file with the same name and the suffix ".meta".
+func getPartFilename(filename, url string) string {
+	var hash = sha1.Sum([]byte(url))
+	return filepath.Join(filepath.Dir(filename),
+		".pixiv-"+hex.EncodeToString(hash[:8])+".part")
+}
+
+// getValidator get the validator of response for "If-Range", ETag is
+// used if it is a strong validator, otherwise Last-Modified is used.
+func getValidator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" &&
+			!strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// getRangeStart get the start of range from "Content-Range" of response
+// like "bytes 100-199/200", it return -1 if it is invalid.
+func getRangeStart(resp *http.Response) int64 {
+	var contentRange = resp.Header.Get("Content-Range")
+	if !strings.HasPrefix(contentRange, "bytes ") {
+		return -1
+	}
+	contentRange = strings.TrimPrefix(contentRange, "bytes ")
+	if i := strings.Index(contentRange, "-"); i >= 0 {
+		if start, err := strconv.ParseInt(
+			contentRange[:i], 10, 64); err == nil {
+			return start
+		}
+	}
+	return -1
+}
+
+// downloadToPart download an image from URL to the partial file. If the
+// partial file exists with a validator from an earlier attempt, only the
+// rest of image is requested with "Range" and "If-Range" and appended to
+// it. The whole image is downloaded again if the server ignores "Range",
+// the image is changed, or the range of response is not the rest of it. The partial file is kept if an error occurs,
+// so it can be resumed next time.
+func (d *Downloader) downloadToPart(ctx context.Context,
+		part, url string) (err error) {
+	var (
+		req       *http.Request
+		resp      *http.Response
+		file      *os.File
+		info      os.FileInfo
+		offset    int64
+		validator []byte
+		meta      = part + ".meta"
+	)
+	
+	if req, err = http.NewRequestWithContext(ctx, "GET", url, nil); err != nil {
+		return err
+	}
+	// Range is on the bytes of image, so the response must not be encoded.
+	req.Header.Set("Accept-Encoding", "identity")
+	if info, err = os.Stat(part); err == nil && info.Size() > 0 {
+		if validator, err = ioutil.ReadFile(meta); err == nil &&
+				len(validator) > 0 {
+			offset = info.Size()
+			req.Header.Set("Range", "bytes="+
+					strconv.FormatInt(offset, 10)+"-")
+			req.Header.Set("If-Range", string(validator))
+		}
+	}
+	
+	if resp, err = d.Client.Do(req); err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent &&
+			getRangeStart(resp) == offset:
+		d.Client.logf("download", "resume \"%s\" from %d bytes", url, offset)
+		file, err = os.OpenFile(part, os.O_WRONLY|os.O_APPEND, 0644)
+	case resp.StatusCode == http.StatusOK:
+		// Save the validator before writing, so the partial file
+		// can be resumed if it is broken.
+		if err = ioutil.WriteFile(meta,
+			[]byte(getValidator(resp)), 0644); err != nil {
+			return err
+		}
+		file, err = os.OpenFile(part,
+			os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		// The range is not the rest of partial file, start again.
+		d.Client.logf("download", "restart \"%s\" because range \"%s\" "+
+				"does not start from %d bytes", url,
+			resp.Header.Get("Content-Range"), offset)
+		if err = removePart(part); err != nil {
+			return err
+		}
+		return d.downloadToPart(ctx, part, url)
+	case offset > 0 && resp.StatusCode ==
+			http.StatusRequestedRangeNotSatisfiable:
+		// The partial file is not a part of the image, start again.
+		d.Client.logf("download", "restart \"%s\" because range is not "+
+				"satisfiable", url)
+		if err = removePart(part); err != nil {
+			return err
+		}
+		return d.downloadToPart(ctx, part, url)
+	default:
+		return throw("download", "request status is not OK when getting image")
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	if _, err = io.Copy(file, resp.Body); err != nil {
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// copyToPart copy an image that is saved to filename to the partial file,
+// the partial file is synced like it is downloaded.
+func copyToPart(part, filename string) (err error) {
+	var src, file *os.File
+	if err = removePart(part); err != nil {
+		return err
+	}
+	if src, err = os.Open(filename); err != nil {
+		return err
+	}
+	defer src.Close()
+	if file, err = os.OpenFile(part,
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	if _, err = io.Copy(file, src); err != nil {
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// removePart remove the partial file and its validator.
+func removePart(part string) (err error) {
+	if err = os.Remove(part + ".meta"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err = os.Remove(part); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package pixiv
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// PosixPolicy only replace "/", NUL and control characters.
+	PosixPolicy = "posix"
+	// WindowsPolicy also replace characters and names that Windows does not
+	// allow, it is safe on both Windows and POSIX file systems.
+	WindowsPolicy = "windows"
+	// StrictPolicy also replace all characters except letters, numbers,
+	// spaces and "-_.,()[]".
+	StrictPolicy = "strict"
+	
+	// MaxNameBytes is the max length in bytes of a name of file or folder.
+	MaxNameBytes = 255
+	// sanitizeReplacer is the replacement of characters that are not allowed.
+	sanitizeReplacer = '_'
+)
+
+// windowsReservedNames save names that can not be used in Windows
+// even if they have an extension.
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// checkSanitizePolicy check the sanitize policy is valid or not.
+func checkSanitizePolicy(policy string) error {
+	switch policy {
+	case PosixPolicy, WindowsPolicy, StrictPolicy:
+		return nil
+	}
+	return &AppError{
+		Prefix: "sanitize",
+		Msg:    "policy \"" + policy + "\" is not \"" + PosixPolicy +
+				"\", \"" + WindowsPolicy + "\" or \"" + StrictPolicy + "\"",
+	}
+}
+
+// sanitizeChars replace characters that are not allowed by policy in str.
+// It is used on values of fields, so a value can not make a new folder.
+func sanitizeChars(str, policy string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == 0 || unicode.IsControl(r):
+			return sanitizeReplacer
+		case policy == PosixPolicy:
+			return r
+		case strings.ContainsRune(`<>:"\|?*`, r):
+			return sanitizeReplacer
+		case policy == WindowsPolicy:
+			return r
+		case unicode.IsLetter(r) || unicode.IsNumber(r) ||
+				strings.ContainsRune(" -_.,()[]", r):
+			return r
+		default:
+			return sanitizeReplacer
+		}
+	}, str)
+}
+
+// sanitizeValue sanitize the value of a field by sanitizeChars, and a
+// value that only has dots is replaced, so a value like "." or ".." can
+// not be the current or parent folder after the path is split by "/".
+func sanitizeValue(value, policy string) string {
+	value = sanitizeChars(value, policy)
+	if value != "" && strings.Trim(value, ".") == "" {
+		value = strings.Repeat(string(sanitizeReplacer), len(value))
+	}
+	return value
+}
+
+// sanitizeName make a name of file or folder allowed by policy and not
+// longer than MaxNameBytes, the extension of name is kept if truncated.
+func sanitizeName(name, policy string) string {
+	name = sanitizeChars(name, policy)
+	
+	// Names that only have dots mean current or parent folder.
+	if strings.Trim(name, ".") == "" {
+		name = strings.Repeat(string(sanitizeReplacer), len(name))
+	}
+	
+	// Windows trim dots and spaces at the end of name, and does not allow
+	// reserved names.
+	if policy != PosixPolicy {
+		if trimmed := strings.TrimRight(name, ". "); trimmed != name {
+			name = trimmed + string(sanitizeReplacer)
+		}
+		var base = strings.ToUpper(strings.SplitN(name, ".", 2)[0])
+		if windowsReservedNames[strings.TrimSpace(base)] {
+			name = string(sanitizeReplacer) + name
+		}
+	}
+	
+	return truncateName(name, MaxNameBytes)
+}
+
+// truncateName truncate name to maxBytes on the boundary of rune and keep
+// the extension of name.
+func truncateName(name string, maxBytes int) string {
+	if len(name) <= maxBytes {
+		return name
+	}
+	var ext = path.Ext(name)
+	if len(ext) >= maxBytes/2 {
+		ext = ""
+	}
+	var base = name[:len(name)-len(ext)]
+	for len(base)+len(ext) > maxBytes {
+		var _, size = utf8.DecodeLastRuneInString(base)
+		base = base[:len(base)-size]
+	}
+	return base + ext
+}
+
+// sanitizePath sanitize each name in a path split by "/" and join it
+// to root, it return an error if the path is out of root.
+func sanitizePath(root, name, policy string) (string, error) {
+	var (
+		names    []string
+		outOfErr = &AppError{
+			Prefix: "sanitize",
+			Msg:    "path \"" + name + "\" is out of \"" + root + "\"",
+		}
+	)
+	for _, n := range strings.Split(name, "/") {
+		if n == ".." {
+			return "", outOfErr
+		} else if n != "" && n != "." {
+			names = append(names, sanitizeName(n, policy))
+		}
+	}
+	
+	var (
+		filename = filepath.Join(root, filepath.Join(names...))
+		rel, err = filepath.Rel(root, filename)
+	)
+	if err != nil || rel == "." || rel == ".." ||
+			strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", outOfErr
+	}
+	return filename, nil
+}
@@ -0,0 +1,120 @@
+package pixiv
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeChars(t *testing.T) {
+	for _, test := range [][3]string{
+		{PosixPolicy, "a/b:c*?", "a_b:c*?"},
+		{PosixPolicy, "a\x00b\nc", "a_b_c"},
+		{WindowsPolicy, `a<b>:"\|?*`, "a_b_______"},
+		{WindowsPolicy, "作品 #1!", "作品 #1!"},
+		{StrictPolicy, "作品 #1!.jpg", "作品 _1_.jpg"},
+		{StrictPolicy, "a-b_c,(d)[e]", "a-b_c,(d)[e]"},
+	} {
+		if str := sanitizeChars(test[1], test[0]); str != test[2] {
+			t.Errorf("%q by %s is %q, not %q", test[1], test[0], str, test[2])
+		}
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	for _, test := range [][3]string{
+		{PosixPolicy, "..", "__"},
+		{PosixPolicy, ".", "_"},
+		{PosixPolicy, "name. ", "name. "},
+		{WindowsPolicy, "name. ", "name_"},
+		{WindowsPolicy, "CON.txt", "_CON.txt"},
+		{WindowsPolicy, "con", "_con"},
+		{WindowsPolicy, "lpt1 .jpg", "_lpt1 .jpg"},
+		{WindowsPolicy, "COM10", "COM10"},
+		{PosixPolicy, "CON.txt", "CON.txt"},
+	} {
+		if name := sanitizeName(test[1], test[0]); name != test[2] {
+			t.Errorf("%q by %s is %q, not %q", test[1], test[0], name, test[2])
+		}
+	}
+}
+
+func TestTruncateName(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		maxBytes int
+		result   string
+	}{
+		{"short.jpg", 20, "short.jpg"},
+		{strings.Repeat("a", 300) + ".jpg", MaxNameBytes,
+			strings.Repeat("a", 251) + ".jpg"},
+		// A rune of 3 bytes is not split.
+		{strings.Repeat("あ", 10) + ".png", 20, "あああああ.png"},
+		{"ab" + strings.Repeat("あ", 10), 10, "abああ"},
+		// An extension that is too long is not kept.
+		{"a.bbbbbbbbbbbb", 10, "a.bbbbbbbb"},
+	} {
+		if result := truncateName(test.name,
+			test.maxBytes); result != test.result {
+			t.Errorf("%q in %d bytes is %q, not %q",
+				test.name, test.maxBytes, result, test.result)
+		}
+	}
+}
+
+func TestSanitizePath(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		path   string
+		errMsg string
+	}{
+		{"a/b.jpg", "a/b.jpg", ""},
+		{"./a//b.jpg", "a/b.jpg", ""},
+		{"a/CON/b.jpg", "a/_CON/b.jpg", ""},
+		{"a/../b.jpg", "", "is out of"},
+		{"..", "", "is out of"},
+		{"", "", "is out of"},
+	} {
+		var filename, err = sanitizePath("root", test.name, WindowsPolicy)
+		if test.errMsg != "" {
+			if err == nil || !strings.Contains(err.Error(), test.errMsg) {
+				t.Errorf("error %v of %q does not contain %q",
+					err, test.name, test.errMsg)
+			}
+		} else if err != nil {
+			t.Errorf("unexpected error of %q: %v", test.name, err)
+		} else if filename != filepath.Join("root",
+			filepath.FromSlash(test.path)) {
+			t.Errorf("path of %q is %q, not %q", test.name, filename,
+				test.path)
+		}
+	}
+}
+
+// TestSanitizePathValues check values of fields like "." or ".." are not
+// folders in the path.
+func TestSanitizePathValues(t *testing.T) {
+	var np, err = compileNaming("<artist.name>/<work.name>", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range [][2]string{
+		{"..", "__"},
+		{".", "_"},
+		{"../x", ".._x"},
+		{"a..", "a_"},
+	} {
+		var (
+			artistData, workData = newTestWork(Illust)
+			filename             string
+		)
+		workData.Name = test[0]
+		if filename, err = sanitizePath("root", np.render(artistData,
+			workData, nil, WindowsPolicy), WindowsPolicy); err != nil {
+			t.Errorf("unexpected error of %q: %v", test[0], err)
+		} else if filename != filepath.Join("root", "Artist", test[1]) {
+			t.Errorf("path of %q is %q, not %q", test[0], filename,
+				filepath.Join("root", "Artist", test[1]))
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package pixiv
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// A Session log in to and log out from Pixiv by Client, the session is
+// kept in the cookie jar of Client and can be saved by Client.SaveCookie.
+type Session struct {
+	Client *Client
+}
+
+// NewSession make a Session of the client.
+func NewSession(client *Client) *Session {
+	return &Session{Client: client}
+}
+
+// IsLoggedIn check that the Session is logged in on Pixiv or not.
+func (s *Session) IsLoggedIn(ctx context.Context) (bool, error) {
+	return s.isLoggedIn(ctx, "session", "request status is not OK when "+
+			"checking that logged in or not")
+}
+
+// isLoggedIn check that the Session is logged in on Pixiv or not, the
+// error of failedMsg has the prefix.
+func (s *Session) isLoggedIn(ctx context.Context,
+		prefix, failedMsg string) (_ bool, err error) {
+	var resp *http.Response
+	if resp, err = s.Client.GetContext(ctx, PixivHomeURL); err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	return checkIsLoggedIn(resp, prefix, failedMsg)
+}
+
+// Login make the Session log in to Pixiv.
+func (s *Session) Login(ctx context.Context,
+		username, password string) (err error) {
+	var (
+		resp       *http.Response
+		postKey    string
+		isLoggedIn bool
+	)
+	
+	// Check that Pixiv is already logged or not.
+	if isLoggedIn, err = s.isLoggedIn(ctx, "login",
+		"request status is not OK when checking "+
+				"that login already or not"); err != nil {
+		return err
+	} else if isLoggedIn {
+		return throw("login", "already logged in")
+	}
+	
+	// Get post key and send a POST request to login.
+	if postKey, err = s.getPostKey(ctx); err != nil {
+		return err
+	}
+	if resp, err = s.Client.PostFormContext(ctx, PixivLoginURL, url.Values{
+		"pixiv_id":  []string{username},
+		"password":  []string{password},
+		"post_key":  []string{postKey},
+		"source":    []string{"pc"},
+		"return_to": []string{PixivHomeURL},
+		"ref":       []string{"wwwtop_accounts_index"},
+	}); err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return throw("login", "request status is not OK when logging in")
+	}
+	
+	// Check that it logged in successful or not.
+	if isLoggedIn, err = checkIsLoggedIn(resp, "login",
+		"request status is not OK when checking "+
+				"that login successful or not"); err != nil {
+		return err
+	} else if !isLoggedIn {
+		return throw("login",
+			"login failed, please check username and password")
+	}
+	
+	return nil
+}
+
+// getPostKey get "post_key" that is needed when login Pixiv.
+func (s *Session) getPostKey(ctx context.Context) (_ string, err error) {
+	var (
+		resp *http.Response
+		body string
+	)
+	
+	// Send a GET request to get the "post_key".
+	if resp, err = s.Client.GetContext(ctx, PixivLoginURL); err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", throw("login",
+			"request status is not OK when getting post key")
+	}
+	if body, err = getResponseBody(resp); err != nil {
+		return "", err
+	}
+	
+	return (&matcher{Prefix: "login"}).match("post_key", body,
+		`<input.*?name="post_key".*?value="(.*?)".*?>`)
+}
+
+// Logout make the Session log out from Pixiv.
+func (s *Session) Logout(ctx context.Context) (err error) {
+	var (
+		resp       *http.Response
+		isLoggedIn bool
+	)
+	
+	// Check that pixiv is already logged or not
+	if isLoggedIn, err = s.isLoggedIn(ctx, "logout",
+		"request status is not OK when checking "+
+				"that not login yet or not"); err != nil {
+		return err
+	} else if !isLoggedIn {
+		return throw("logout", "not logged in yet")
+	}
+	
+	// Send a GET request to logout
+	if resp, err = s.Client.GetContext(ctx, PixivLogoutURL); err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return throw("logout", "request status is not OK when logging out")
+	}
+	
+	// Check that it logged out successful or not
+	if isLoggedIn, err = s.isLoggedIn(ctx, "logout",
+		"request status is not OK when checking "+
+				"that logout successful or not"); err != nil {
+		return err
+	} else if isLoggedIn {
+		return throw("logout", "logout failed")
+	}
+	
+	return nil
+}
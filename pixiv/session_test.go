@@ -0,0 +1,53 @@
+package pixiv
+
+import (
+	"context"
+	"testing"
+	
+	"github.com/abc1236762/pixiv_tool/internal/golden"
+)
+
+func TestSessionLogin(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		isLoggedIn bool
+		password   string
+	}{
+		{"LoggedOut", false, fakePassword},
+		{"LoggedIn", true, fakePassword},
+		{"WrongPassword", false, "wrong-password"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				fp      = newFakePixiv(t)
+				session = NewSession(fp.client(test.isLoggedIn))
+				g       golden.File
+			)
+			g.Result(session.Login(context.Background(),
+				fakeUsername, test.password))
+			g.Section("requests", fp.getRequests(false)...)
+			g.Check(t)
+		})
+	}
+}
+
+func TestSessionLogout(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		isLoggedIn bool
+	}{
+		{"LoggedIn", true},
+		{"LoggedOut", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				fp      = newFakePixiv(t)
+				session = NewSession(fp.client(test.isLoggedIn))
+				g       golden.File
+			)
+			g.Result(session.Logout(context.Background()))
+			g.Section("requests", fp.getRequests(false)...)
+			g.Check(t)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// TestNotifySignals check the context is canceled by a signal, and the
+// signal is returned by stop.
+func TestNotifySignals(t *testing.T) {
+	var ctx, stop = notifySignals()
+	if received := stop(); received != nil {
+		t.Errorf("signal %v is received without sending", received)
+	} else if ctx.Err() == nil {
+		t.Error("the context is not canceled by stop")
+	}
+	
+	ctx, stop = notifySignals()
+	var process, err = os.FindProcess(os.Getpid())
+	if err != nil {
+		stop()
+		t.Fatal(err)
+	}
+	if err = process.Signal(os.Interrupt); err != nil {
+		stop()
+		t.Skipf("signal can not be sent: %v", err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Error("the context is not canceled by the signal")
+	}
+	if received := stop(); received != os.Interrupt {
+		t.Errorf("received signal is %v, not %v", received, os.Interrupt)
+	}
+}